@@ -0,0 +1,201 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"plocate-ui/config"
+)
+
+// Outcomes of an audited call.
+const (
+	OutcomeSuccess = "success"
+	OutcomeDenied  = "denied"
+	OutcomeFailure = "failure"
+)
+
+// logName is the file entries are appended to. Rotated files get a numeric
+// suffix, .1 being the most recent.
+const logName = "audit.log"
+
+// Entry is one mutating API call.
+type Entry struct {
+	Time     time.Time      `json:"time"`
+	Actor    string         `json:"actor"`
+	Role     string         `json:"role,omitempty"`
+	Auth     string         `json:"auth,omitempty"` // "session", "token" or "forward"; empty for sign-in attempts
+	ClientIP string         `json:"client_ip"`
+	Method   string         `json:"method"`
+	Path     string         `json:"path"`
+	Route    string         `json:"route"` // Route pattern, e.g. /api/indices/:indexName
+	Params   map[string]any `json:"params,omitempty"`
+	Status   int            `json:"status"`
+	Outcome  string         `json:"outcome"`
+	Error    string         `json:"error,omitempty"`
+
+	RequestID string `json:"request_id,omitempty"` // Matches the server log and build events
+}
+
+// Filter selects entries from the log. Zero fields match everything.
+type Filter struct {
+	Actor    string
+	ClientIP string
+	Method   string
+	Path     string // Substring of the request path
+	Outcome  string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+var (
+	mu   sync.Mutex
+	file *os.File
+	size int64
+)
+
+func logPath(n int) string {
+	path := filepath.Join(config.AppConfig.Audit.Dir, logName)
+	if n > 0 {
+		path = fmt.Sprintf("%s.%d", path, n)
+	}
+	return path
+}
+
+// Record appends entry to the audit log, rotating the log first if it would
+// grow past audit.max_size_mb. Failures are logged and otherwise ignored so
+// that auditing never blocks the action itself.
+func Record(entry Entry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		slog.Error("audit entry not recorded", "error", err)
+		return
+	}
+	line = append(line, '\n')
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := open(); err != nil {
+		slog.Error("audit entry not recorded", "error", err)
+		return
+	}
+	maxSize := int64(config.AppConfig.Audit.MaxSizeMB) << 20
+	if size > 0 && size+int64(len(line)) > maxSize {
+		if err := rotate(); err != nil {
+			slog.Error("audit log not rotated", "error", err)
+		}
+		if err := open(); err != nil {
+			slog.Error("audit entry not recorded", "error", err)
+			return
+		}
+	}
+
+	n, err := file.Write(line)
+	size += int64(n)
+	if err != nil {
+		slog.Error("audit entry not recorded", "error", err)
+	}
+}
+
+// open opens the current log for appending if it is not open yet. Caller
+// must hold mu.
+func open() error {
+	if file != nil {
+		return nil
+	}
+	if err := os.MkdirAll(config.AppConfig.Audit.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	f, err := os.OpenFile(logPath(0), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	file, size = f, info.Size()
+	return nil
+}
+
+// rotate shifts audit.log to audit.log.1, audit.log.1 to audit.log.2 and so
+// on, dropping the oldest file beyond audit.retain. Caller must hold mu.
+func rotate() error {
+	file.Close()
+	file, size = nil, 0
+
+	retain := config.AppConfig.Audit.Retain
+	if err := os.Remove(logPath(retain)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for n := retain - 1; n >= 0; n-- {
+		if err := os.Rename(logPath(n), logPath(n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f Filter) match(e Entry) bool {
+	switch {
+	case f.Actor != "" && e.Actor != f.Actor,
+		f.ClientIP != "" && e.ClientIP != f.ClientIP,
+		f.Method != "" && !strings.EqualFold(e.Method, f.Method),
+		f.Path != "" && !strings.Contains(e.Path, f.Path),
+		f.Outcome != "" && e.Outcome != f.Outcome,
+		!f.Since.IsZero() && e.Time.Before(f.Since),
+		!f.Until.IsZero() && e.Time.After(f.Until):
+		return false
+	}
+	return true
+}
+
+// Query returns the most recent entries matching filter, newest first,
+// searching the current log and every rotated file.
+func Query(filter Filter) ([]Entry, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var matches []Entry
+	for n := config.AppConfig.Audit.Retain; n >= 0; n-- {
+		f, err := os.Open(logPath(n))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var e Entry
+			if json.Unmarshal(scanner.Bytes(), &e) != nil || !filter.match(e) {
+				continue
+			}
+			matches = append(matches, e)
+			if filter.Limit > 0 && len(matches) > filter.Limit {
+				matches = matches[1:]
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
+
+	entries := make([]Entry, len(matches))
+	for i, e := range matches {
+		entries[len(matches)-1-i] = e
+	}
+	return entries, nil
+}
@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"plocate-ui/auth"
+	"plocate-ui/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBody is the largest request body decoded into an entry's parameters.
+const maxBody = 64 * 1024
+
+// maxResponse is how much of a response is kept to find its error message.
+const maxResponse = 4 * 1024
+
+// responseRecorder keeps the start of a response body so a failed call's
+// error message can be recorded.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if room := maxResponse - r.body.Len(); room > 0 {
+		r.body.Write(b[:min(room, len(b))])
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	return r.Write([]byte(s))
+}
+
+// Middleware records every request that is not a GET, HEAD or OPTIONS. Routes
+// listed in readOnly, such as POST searches, are skipped. It must run after
+// auth.Middleware on authenticated groups; on public routes the actor is the
+// signed-in user, if any, or the username the request names.
+func Middleware(readOnly ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(readOnly))
+	for _, route := range readOnly {
+		skip[route] = true
+	}
+
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if skip[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		entry := Entry{
+			Time:     time.Now(),
+			Actor:    auth.Username(c),
+			Role:     auth.Role(c),
+			Auth:     c.GetString(auth.ContextMethod),
+			ClientIP: auth.ClientIP(c),
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+			Route:    c.FullPath(),
+			Params:   requestParams(c),
+
+			RequestID: logging.RequestID(c.Request.Context()),
+		}
+		if entry.Actor == "" {
+			// Public routes: sign-out still has a session to name
+			if id, method, ok := auth.Resolve(c); ok {
+				entry.Actor, entry.Role, entry.Auth = id.Username, id.Role, method
+			} else if body, ok := entry.Params["body"].(map[string]any); ok {
+				entry.Actor, _ = body["username"].(string)
+			}
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		entry.Status = recorder.Status()
+		switch {
+		case entry.Status == http.StatusUnauthorized || entry.Status == http.StatusForbidden:
+			entry.Outcome = OutcomeDenied
+		case entry.Status >= http.StatusBadRequest:
+			entry.Outcome = OutcomeFailure
+		default:
+			entry.Outcome = OutcomeSuccess
+		}
+		if entry.Status >= http.StatusBadRequest {
+			var resp struct {
+				Error string `json:"error"`
+			}
+			if json.Unmarshal(recorder.body.Bytes(), &resp) == nil {
+				entry.Error = resp.Error
+			}
+		}
+
+		Record(entry)
+	}
+}
+
+// requestParams collects the path parameters, query string and JSON body of
+// a request, with secrets redacted. The body is restored for the handler.
+func requestParams(c *gin.Context) map[string]any {
+	params := make(map[string]any)
+	for _, p := range c.Params {
+		params[p.Key] = p.Value
+	}
+
+	if query := c.Request.URL.Query(); len(query) > 0 {
+		q := make(map[string]any, len(query))
+		for k, v := range query {
+			if len(v) == 1 {
+				q[k] = v[0]
+			} else {
+				q[k] = v
+			}
+		}
+		params["query"] = redact(q)
+	}
+
+	if c.Request.Body != nil && strings.HasPrefix(c.ContentType(), "application/json") {
+		data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBody+1))
+		c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), c.Request.Body))
+
+		var body any
+		if err == nil && len(data) <= maxBody && json.Unmarshal(data, &body) == nil {
+			params["body"] = redact(body)
+		}
+	}
+
+	if len(params) == 0 {
+		return nil
+	}
+	return params
+}
+
+// redact replaces values whose key names a password, token or secret.
+func redact(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, val := range v {
+			key := strings.ToLower(k)
+			if strings.Contains(key, "password") || strings.Contains(key, "token") || strings.Contains(key, "secret") {
+				v[k] = "[redacted]"
+			} else {
+				v[k] = redact(val)
+			}
+		}
+	case []any:
+		for i, val := range v {
+			v[i] = redact(val)
+		}
+	}
+	return v
+}
@@ -0,0 +1,290 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"plocate-ui/config"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SessionCookie is the cookie that carries a UI session.
+const SessionCookie = "plocate_session"
+
+// MinPasswordLength is the shortest password accepted for a local user.
+const MinPasswordLength = 8
+
+// Context keys set by Middleware for downstream handlers.
+const (
+	ContextUser     = "auth_user"
+	ContextRole     = "auth_role"
+	ContextIdentity = "auth_identity"
+	ContextMethod   = "auth_method" // "session" or "token"
+)
+
+// tokenPrefix marks API tokens so they are recognisable in scripts and logs.
+const tokenPrefix = "plk_"
+
+type session struct {
+	username string
+	expires  time.Time
+}
+
+var (
+	mu       sync.Mutex
+	sessions = make(map[string]session)
+
+	dummyOnce sync.Once
+	dummyHash []byte
+)
+
+// HashPassword returns a bcrypt hash of password.
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// Authenticate checks a username and password against the configured users.
+func Authenticate(username, password string) bool {
+	user, ok := config.FindUser(username)
+	if !ok {
+		// Spend the same time as a real check so usernames cannot be probed
+		dummyOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte(randomHex(16)), bcrypt.DefaultCost)
+		})
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// HashToken returns the stored form of an API token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAPIToken creates an API token for username. The returned string is the
+// only copy of the token; the config keeps its hash.
+func NewAPIToken(name, username string) (string, config.APIToken) {
+	token := tokenPrefix + randomHex(32)
+	return token, config.APIToken{
+		Name:      name,
+		Username:  username,
+		Hash:      HashToken(token),
+		Prefix:    token[:len(tokenPrefix)+6],
+		CreatedAt: time.Now(),
+	}
+}
+
+func sessionTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.AppConfig.Auth.SessionTTL)
+	if err != nil {
+		return 24 * time.Hour
+	}
+	return ttl
+}
+
+// StartSession signs username in and sets the session cookie.
+func StartSession(c *gin.Context, username string) {
+	id := randomHex(32)
+	ttl := sessionTTL()
+
+	mu.Lock()
+	now := time.Now()
+	for k, s := range sessions {
+		if now.After(s.expires) {
+			delete(sessions, k)
+		}
+	}
+	sessions[id] = session{username: username, expires: now.Add(ttl)}
+	mu.Unlock()
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(SessionCookie, id, int(ttl.Seconds()), "/", "", c.Request.TLS != nil, true)
+}
+
+// EndSession signs the current session out and clears the cookie.
+func EndSession(c *gin.Context) {
+	if id, err := c.Cookie(SessionCookie); err == nil {
+		mu.Lock()
+		delete(sessions, id)
+		mu.Unlock()
+	}
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(SessionCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+}
+
+// EndUserSessions signs out every session of username, e.g. after a password
+// change or when the user is removed. keep, if set, survives.
+func EndUserSessions(username, keep string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for id, s := range sessions {
+		if s.username == username && id != keep {
+			delete(sessions, id)
+		}
+	}
+}
+
+// Identify returns the user a request is signed in as, and how.
+func Identify(c *gin.Context) (string, string, bool) {
+	if header := c.GetHeader("Authorization"); header != "" {
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return "", "", false
+		}
+		t, ok := config.FindAPIToken(HashToken(strings.TrimSpace(token)))
+		if !ok {
+			return "", "", false
+		}
+		if _, exists := config.FindUser(t.Username); !exists {
+			return "", "", false
+		}
+		return t.Username, "token", true
+	}
+
+	id, err := c.Cookie(SessionCookie)
+	if err != nil {
+		return "", "", false
+	}
+
+	mu.Lock()
+	s, ok := sessions[id]
+	if ok && time.Now().After(s.expires) {
+		delete(sessions, id)
+		ok = false
+	}
+	mu.Unlock()
+
+	if !ok {
+		return "", "", false
+	}
+	if _, exists := config.FindUser(s.username); !exists {
+		return "", "", false
+	}
+	return s.username, "session", true
+}
+
+// SessionID returns the session cookie of the request, if any.
+func SessionID(c *gin.Context) string {
+	id, _ := c.Cookie(SessionCookie)
+	return id
+}
+
+// Resolve returns the identity of a request and how it authenticated:
+// "forward" for a trusted proxy header, "token" or "session".
+func Resolve(c *gin.Context) (*config.Identity, string, bool) {
+	if id, ok := forwardIdentity(c); ok {
+		return id, "forward", true
+	}
+
+	username, method, ok := Identify(c)
+	if !ok {
+		return nil, "", false
+	}
+	user, ok := config.FindUser(username)
+	if !ok {
+		return nil, "", false
+	}
+	return &config.Identity{Username: username, Role: user.Role, Groups: user.Groups}, method, true
+}
+
+// SetupRequired reports whether nobody can sign in yet: no local user exists
+// and forward auth is off.
+func SetupRequired() bool {
+	return !config.HasUsers() && !config.AppConfig.Auth.ForwardAuth.Enabled
+}
+
+// Middleware rejects requests that are not signed in with a session cookie,
+// bearer API token or trusted forward-auth header. Until the first user is
+// created every request is refused with setup_required so the UI can show the
+// setup flow.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if SetupRequired() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "no users exist; complete setup first", "setup_required": true})
+			return
+		}
+
+		id, method, ok := Resolve(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+
+		c.Set(ContextUser, id.Username)
+		c.Set(ContextRole, id.Role)
+		c.Set(ContextIdentity, id)
+		c.Set(ContextMethod, method)
+		c.Next()
+	}
+}
+
+// RequireRole rejects requests from users below role. It must run after
+// Middleware.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.RoleAtLeast(Role(c), role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": role + " role required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// IndexAccess rejects requests for an :indexName the user may not search. It
+// must run after Middleware.
+func IndexAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("indexName")
+		if name != "" && !Identity(c).CanSearchIndex(name) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "access to index '" + name + "' denied"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Identity returns the access identity Middleware built for this request.
+// Requests that did not pass through Middleware get an identity with no role,
+// which is never granted more than an ordinary user.
+func Identity(c *gin.Context) *config.Identity {
+	if id, ok := c.Get(ContextIdentity); ok {
+		return id.(*config.Identity)
+	}
+	return &config.Identity{}
+}
+
+// Role returns the role of the user Middleware identified for this request.
+func Role(c *gin.Context) string {
+	return c.GetString(ContextRole)
+}
+
+// Username returns the user Middleware identified for this request.
+func Username(c *gin.Context) string {
+	return c.GetString(ContextUser)
+}
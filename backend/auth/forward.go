@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"net"
+	"strings"
+
+	"plocate-ui/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trustedPeer reports whether the request's TCP peer is a trusted proxy.
+// X-Forwarded-For is deliberately ignored: only the proxy itself may vouch
+// for the user.
+func trustedPeer(c *gin.Context, proxies []string) bool {
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		host = c.Request.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range proxies {
+		if _, network, err := net.ParseCIDR(proxy); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address a request came from. Behind a trusted
+// forward-auth proxy it is the last hop the proxy appended to
+// X-Forwarded-For; otherwise it is the TCP peer.
+func ClientIP(c *gin.Context) string {
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		host = c.Request.RemoteAddr
+	}
+
+	fa := config.AppConfig.Auth.ForwardAuth
+	if fa.Enabled && trustedPeer(c, fa.TrustedProxies) {
+		hops := strings.Split(c.GetHeader("X-Forwarded-For"), ",")
+		if last := strings.TrimSpace(hops[len(hops)-1]); net.ParseIP(last) != nil {
+			return last
+		}
+	}
+	return host
+}
+
+// forwardIdentity returns the user named by a trusted reverse proxy. A local
+// account with the same name supplies the role and extra groups; otherwise
+// the role comes from role_groups or default_role.
+func forwardIdentity(c *gin.Context) (*config.Identity, bool) {
+	fa := config.AppConfig.Auth.ForwardAuth
+	if !fa.Enabled || !trustedPeer(c, fa.TrustedProxies) {
+		return nil, false
+	}
+
+	username := strings.TrimSpace(c.GetHeader(fa.UserHeader))
+	if username == "" {
+		return nil, false
+	}
+
+	var groups []string
+	for _, g := range strings.Split(c.GetHeader(fa.GroupsHeader), fa.GroupsSeparator) {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+
+	id := &config.Identity{Username: username, Role: fa.DefaultRole, Groups: groups}
+	if user, ok := config.FindUser(username); ok {
+		id.Role = user.Role
+		id.Groups = append(id.Groups, user.Groups...)
+		return id, true
+	}
+
+	for _, role := range []string{config.RoleAdmin, config.RoleOperator, config.RoleViewer} {
+		if inAny(groups, fa.RoleGroups[role]) {
+			if config.RoleAtLeast(role, id.Role) {
+				id.Role = role
+			}
+			break
+		}
+	}
+	return id, true
+}
+
+func inAny(groups, wanted []string) bool {
+	for _, g := range groups {
+		for _, w := range wanted {
+			if g == w {
+				return true
+			}
+		}
+	}
+	return false
+}
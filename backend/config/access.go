@@ -0,0 +1,197 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrAccessDenied is returned when a user may not search an index.
+var ErrAccessDenied = errors.New("access denied")
+
+// PathRule limits the search results, change records and stats paths the
+// matching users see. A rule with no users and no groups applies to everyone.
+type PathRule struct {
+	Users  []string `yaml:"users,omitempty" json:"users,omitempty"`
+	Groups []string `yaml:"groups,omitempty" json:"groups,omitempty"`
+	Allow  []string `yaml:"allow,omitempty" json:"allow,omitempty"` // Only paths under these prefixes are shown
+	Deny   []string `yaml:"deny,omitempty" json:"deny,omitempty"`   // Paths under these prefixes are hidden
+}
+
+// Identity is the user a request acts for. A nil Identity is the app itself,
+// e.g. the scheduler evaluating saved searches, and is never restricted.
+type Identity struct {
+	Username string
+	Role     string
+	Groups   []string
+}
+
+func validatePathRules(cfg *Config) error {
+	for i := range cfg.Auth.PathRules {
+		rule := &cfg.Auth.PathRules[i]
+		if len(rule.Allow) == 0 && len(rule.Deny) == 0 {
+			return fmt.Errorf("path rule %d must set allow or deny", i+1)
+		}
+		for _, prefixes := range [][]string{rule.Allow, rule.Deny} {
+			for j, p := range prefixes {
+				if !filepath.IsAbs(p) {
+					return fmt.Errorf("path rule %d: %q is not an absolute path", i+1, p)
+				}
+				prefixes[j] = filepath.Clean(p)
+			}
+		}
+	}
+	return nil
+}
+
+func (id *Identity) matches(users, groups []string) bool {
+	for _, u := range users {
+		if u == id.Username {
+			return true
+		}
+	}
+	for _, g := range groups {
+		for _, mine := range id.Groups {
+			if g == mine {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CanSearch reports whether id may search index. Indices without
+// allowed_users or allowed_groups are open to every user; admins can search
+// every index.
+func (id *Identity) CanSearch(index IndexConfig) bool {
+	if id == nil || id.Role == RoleAdmin {
+		return true
+	}
+	if len(index.AllowedUsers) == 0 && len(index.AllowedGroups) == 0 {
+		return true
+	}
+	return id.matches(index.AllowedUsers, index.AllowedGroups)
+}
+
+// CanSearchIndex is CanSearch for an index by name. Unknown indices are
+// allowed so callers report them as not found instead; it is not suitable
+// for deciding who may see data about indices that may have been removed.
+func (id *Identity) CanSearchIndex(name string) bool {
+	index, ok := FindIndex(name)
+	return !ok || id.CanSearch(index)
+}
+
+// pathRules returns the rules that apply to id.
+func (id *Identity) pathRules() []PathRule {
+	if id == nil {
+		return nil
+	}
+	var rules []PathRule
+	for _, rule := range AppConfig.Auth.PathRules {
+		if (len(rule.Users) == 0 && len(rule.Groups) == 0) || id.matches(rule.Users, rule.Groups) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// HasPathRules reports whether any path rule restricts id.
+func (id *Identity) HasPathRules() bool {
+	return len(id.pathRules()) > 0
+}
+
+func underPrefix(path, prefix string) bool {
+	return path == prefix || prefix == "/" || strings.HasPrefix(path, prefix+"/")
+}
+
+// PathAllowed reports whether id may see path: it must not be under any deny
+// prefix and, if any applicable rule lists allow prefixes, must be under one
+// of them.
+func (id *Identity) PathAllowed(path string) bool {
+	rules := id.pathRules()
+	if len(rules) == 0 {
+		return true
+	}
+
+	var allow []string
+	for _, rule := range rules {
+		for _, p := range rule.Deny {
+			if underPrefix(path, p) {
+				return false
+			}
+		}
+		allow = append(allow, rule.Allow...)
+	}
+	if len(allow) == 0 {
+		return true
+	}
+	for _, p := range allow {
+		if underPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterPaths returns the paths id may see.
+func (id *Identity) FilterPaths(paths []string) []string {
+	if !id.HasPathRules() {
+		return paths
+	}
+	filtered := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if id.PathAllowed(p) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// CanSeeResult reports whether id may see a path found by a search that was
+// not limited to its indices, such as a recorded saved search. The path must
+// pass the path rules and, if some indices are closed to id, lie under the
+// index paths of one it may search.
+func (id *Identity) CanSeeResult(path string) bool {
+	if !id.PathAllowed(path) {
+		return false
+	}
+	if id == nil || id.Role == RoleAdmin {
+		return true
+	}
+
+	restricted := false
+	for _, index := range AppConfig.Plocate.Indices {
+		if !id.CanSearch(index) {
+			restricted = true
+			break
+		}
+	}
+	if !restricted {
+		return true
+	}
+
+	for _, index := range AppConfig.Plocate.Indices {
+		if !id.CanSearch(index) {
+			continue
+		}
+		for _, p := range index.IndexPaths {
+			if underPrefix(path, filepath.Clean(p)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FilterResults returns the paths from an unrestricted search that id may
+// see.
+func (id *Identity) FilterResults(paths []string) []string {
+	filtered := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if id.CanSeeResult(p) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
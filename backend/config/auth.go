@@ -0,0 +1,347 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Roles, from least to most privileged. Each role can do everything the
+// roles before it can.
+const (
+	RoleViewer   = "viewer"   // Search and view status
+	RoleOperator = "operator" // Also start/stop builds and toggle the scheduler
+	RoleAdmin    = "admin"    // Also manage indices, users and settings
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// ValidateRole checks that role is viewer, operator or admin.
+func ValidateRole(role string) error {
+	if _, ok := roleRank[role]; !ok {
+		return fmt.Errorf("invalid role %q: must be viewer, operator or admin", role)
+	}
+	return nil
+}
+
+// RoleAtLeast reports whether role grants everything min does.
+func RoleAtLeast(role, min string) bool {
+	return roleRank[role] >= roleRank[min]
+}
+
+// User is a local account that can sign in to the web UI.
+type User struct {
+	Username     string   `yaml:"username" json:"username"`
+	PasswordHash string   `yaml:"password_hash" json:"-"` // bcrypt
+	Role         string   `yaml:"role" json:"role"`
+	Groups       []string `yaml:"groups,omitempty" json:"groups,omitempty"` // Used by index and path access rules
+}
+
+// APIToken is a bearer token for scripts. Only a SHA-256 hash of the token is
+// stored; the token itself is shown once when it is created.
+type APIToken struct {
+	Name      string    `yaml:"name" json:"name"`
+	Username  string    `yaml:"username" json:"username"` // Account the token acts as
+	Hash      string    `yaml:"hash" json:"-"`
+	Prefix    string    `yaml:"prefix" json:"prefix"` // First characters of the token, to tell tokens apart
+	CreatedAt time.Time `yaml:"created_at" json:"created_at"`
+}
+
+// ForwardAuth trusts a user name and groups set by an authenticating reverse
+// proxy such as Authelia, Authentik or Traefik forward auth. The headers are
+// only read from requests whose TCP peer is in TrustedProxies.
+type ForwardAuth struct {
+	Enabled         bool                `yaml:"enabled"`
+	UserHeader      string              `yaml:"user_header,omitempty"`      // Default "Remote-User"
+	GroupsHeader    string              `yaml:"groups_header,omitempty"`    // Default "Remote-Groups"
+	GroupsSeparator string              `yaml:"groups_separator,omitempty"` // Default ","
+	TrustedProxies  []string            `yaml:"trusted_proxies"`            // CIDRs or addresses
+	DefaultRole     string              `yaml:"default_role,omitempty"`     // Role of proxy users with no local account or role group; default viewer
+	RoleGroups      map[string][]string `yaml:"role_groups,omitempty"`      // Role -> proxy groups granting it, e.g. admin: [admins]
+}
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,63}$`)
+
+// ValidateUsername checks that a username is 1-64 letters, digits, '.', '_',
+// '@' or '-', starting with a letter or digit.
+func ValidateUsername(name string) error {
+	if !usernamePattern.MatchString(name) {
+		return fmt.Errorf("invalid username %q: use 1-64 letters, digits, '.', '_', '@' or '-', starting with a letter or digit", name)
+	}
+	return nil
+}
+
+func validateForwardAuth(cfg *Config) error {
+	fa := &cfg.Auth.ForwardAuth
+	if fa.UserHeader == "" {
+		fa.UserHeader = "Remote-User"
+	}
+	if fa.GroupsHeader == "" {
+		fa.GroupsHeader = "Remote-Groups"
+	}
+	if fa.GroupsSeparator == "" {
+		fa.GroupsSeparator = ","
+	}
+	if fa.DefaultRole == "" {
+		fa.DefaultRole = RoleViewer
+	}
+	if err := ValidateRole(fa.DefaultRole); err != nil {
+		return fmt.Errorf("auth.forward_auth.default_role: %w", err)
+	}
+	for role := range fa.RoleGroups {
+		if err := ValidateRole(role); err != nil {
+			return fmt.Errorf("auth.forward_auth.role_groups: %w", err)
+		}
+	}
+	for i, proxy := range fa.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil {
+			return fmt.Errorf("invalid auth.forward_auth.trusted_proxies entry %q", fa.TrustedProxies[i])
+		}
+		fa.TrustedProxies[i] = proxy
+	}
+	if fa.Enabled && len(fa.TrustedProxies) == 0 {
+		return fmt.Errorf("auth.forward_auth requires trusted_proxies")
+	}
+	return nil
+}
+
+func validateAuth(cfg *Config) error {
+	if d, err := time.ParseDuration(cfg.Auth.SessionTTL); err != nil || d <= 0 {
+		return fmt.Errorf("invalid auth.session_ttl %q", cfg.Auth.SessionTTL)
+	}
+	seen := make(map[string]bool)
+	for i := range cfg.Auth.Users {
+		user := &cfg.Auth.Users[i]
+		if err := ValidateUsername(user.Username); err != nil {
+			return err
+		}
+		// Users created before roles existed had full access
+		if user.Role == "" {
+			user.Role = RoleAdmin
+		}
+		if err := ValidateRole(user.Role); err != nil {
+			return fmt.Errorf("user %s: %w", user.Username, err)
+		}
+		if seen[user.Username] {
+			return fmt.Errorf("duplicate user %q", user.Username)
+		}
+		if user.PasswordHash == "" {
+			return fmt.Errorf("user %s has no password_hash", user.Username)
+		}
+		seen[user.Username] = true
+	}
+	return nil
+}
+
+// HasUsers reports whether any local account exists. Until one does, the app
+// only serves the first-run setup flow.
+func HasUsers() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return len(AppConfig.Auth.Users) > 0
+}
+
+// FindUser returns a copy of the named user.
+func FindUser(username string) (User, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, u := range AppConfig.Auth.Users {
+		if u.Username == username {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+// Users returns a copy of all users.
+func Users() []User {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return append([]User{}, AppConfig.Auth.Users...)
+}
+
+// SetupUser creates the first user. It fails once any user exists, so the
+// setup flow cannot be used to add accounts later.
+func SetupUser(user User) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(AppConfig.Auth.Users) > 0 {
+		return fmt.Errorf("setup has already been completed")
+	}
+
+	AppConfig.Auth.Users = append(AppConfig.Auth.Users, user)
+	return saveLocked()
+}
+
+// AddUser adds a user to the config and persists it.
+func AddUser(user User) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, u := range AppConfig.Auth.Users {
+		if u.Username == user.Username {
+			return fmt.Errorf("user '%s' already exists", user.Username)
+		}
+	}
+
+	AppConfig.Auth.Users = append(AppConfig.Auth.Users, user)
+	return saveLocked()
+}
+
+// adminCount returns the number of admins in users.
+func adminCount(users []User) int {
+	n := 0
+	for _, u := range users {
+		if u.Role == RoleAdmin {
+			n++
+		}
+	}
+	return n
+}
+
+// UpdateUser changes a user's role and, if groups is not nil, their groups,
+// and persists it. The last admin cannot be demoted.
+func UpdateUser(username, role string, groups []string) (*User, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i, u := range AppConfig.Auth.Users {
+		if u.Username != username {
+			continue
+		}
+		if u.Role == RoleAdmin && role != RoleAdmin && adminCount(AppConfig.Auth.Users) == 1 {
+			return nil, fmt.Errorf("cannot demote the last admin")
+		}
+		AppConfig.Auth.Users[i].Role = role
+		if groups != nil {
+			AppConfig.Auth.Users[i].Groups = groups
+		}
+		if err := saveLocked(); err != nil {
+			return nil, err
+		}
+		user := AppConfig.Auth.Users[i]
+		return &user, nil
+	}
+	return nil, fmt.Errorf("user '%s' not found", username)
+}
+
+// RemoveUser removes a user and their API tokens. The last admin cannot be
+// removed.
+func RemoveUser(username string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	found := false
+	users := make([]User, 0, len(AppConfig.Auth.Users))
+	for _, u := range AppConfig.Auth.Users {
+		if u.Username == username {
+			found = true
+			continue
+		}
+		users = append(users, u)
+	}
+
+	if !found {
+		return fmt.Errorf("user '%s' not found", username)
+	}
+	if adminCount(users) == 0 {
+		return fmt.Errorf("cannot remove the last admin")
+	}
+
+	tokens := make([]APIToken, 0, len(AppConfig.Auth.Tokens))
+	for _, t := range AppConfig.Auth.Tokens {
+		if t.Username != username {
+			tokens = append(tokens, t)
+		}
+	}
+
+	AppConfig.Auth.Users = users
+	AppConfig.Auth.Tokens = tokens
+	return saveLocked()
+}
+
+// SetPasswordHash replaces a user's password hash and persists it.
+func SetPasswordHash(username, hash string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i, u := range AppConfig.Auth.Users {
+		if u.Username == username {
+			AppConfig.Auth.Users[i].PasswordHash = hash
+			return saveLocked()
+		}
+	}
+	return fmt.Errorf("user '%s' not found", username)
+}
+
+// APITokens returns a copy of all API tokens.
+func APITokens() []APIToken {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return append([]APIToken{}, AppConfig.Auth.Tokens...)
+}
+
+// FindAPIToken returns the token with the given hash.
+func FindAPIToken(hash string) (APIToken, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, t := range AppConfig.Auth.Tokens {
+		if t.Hash == hash {
+			return t, true
+		}
+	}
+	return APIToken{}, false
+}
+
+// AddAPIToken adds an API token to the config and persists it.
+func AddAPIToken(token APIToken) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, t := range AppConfig.Auth.Tokens {
+		if t.Name == token.Name {
+			return fmt.Errorf("API token '%s' already exists", token.Name)
+		}
+	}
+
+	AppConfig.Auth.Tokens = append(AppConfig.Auth.Tokens, token)
+	return saveLocked()
+}
+
+// RemoveAPIToken removes an API token from the config and persists it.
+func RemoveAPIToken(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	found := false
+	tokens := make([]APIToken, 0, len(AppConfig.Auth.Tokens))
+	for _, t := range AppConfig.Auth.Tokens {
+		if t.Name == name {
+			found = true
+			continue
+		}
+		tokens = append(tokens, t)
+	}
+
+	if !found {
+		return fmt.Errorf("API token '%s' not found", name)
+	}
+
+	AppConfig.Auth.Tokens = tokens
+	return saveLocked()
+}
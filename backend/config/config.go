@@ -4,22 +4,144 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type IndexConfig struct {
-	Name         string   `yaml:"name"`
-	DatabasePath string   `yaml:"database_path"`
-	IndexPaths   []string `yaml:"index_paths"`
-	Enabled      bool     `yaml:"enabled"`
+	Name         string   `yaml:"name" json:"name"`
+	DatabasePath string   `yaml:"database_path" json:"database_path"`
+	IndexPaths   []string `yaml:"index_paths" json:"index_paths"`
+	Enabled      bool     `yaml:"enabled" json:"enabled"`
+	Priority     int      `yaml:"priority,omitempty" json:"priority,omitempty"` // Higher runs first when queue ordering is "priority"
+	Disk         string   `yaml:"disk,omitempty" json:"disk,omitempty"`         // Physical disk key for per-disk limits; derived from index_paths if empty
+	Managed      bool     `yaml:"managed,omitempty" json:"managed,omitempty"`   // Database lives in data_dir and moves with it
+
+	// Users and groups allowed to search this index; both empty = everyone
+	AllowedUsers  []string `yaml:"allowed_users,omitempty" json:"allowed_users,omitempty"`
+	AllowedGroups []string `yaml:"allowed_groups,omitempty" json:"allowed_groups,omitempty"`
+
+	// Per-index overrides for indexing.resources; zero fields inherit the global value
+	Resources *ResourceLimits `yaml:"resources,omitempty" json:"resources,omitempty"`
+
+	// Per-index overrides for indexing.retry; zero fields inherit the global value
+	Retry *RetryPolicy `yaml:"retry,omitempty" json:"retry,omitempty"`
+}
+
+// RetryPolicy controls automatic retries after a failed build.
+type RetryPolicy struct {
+	MaxAttempts    int    `yaml:"max_attempts,omitempty" json:"max_attempts,omitempty"`       // Retries after a failure; 0 = no retries
+	InitialBackoff string `yaml:"initial_backoff,omitempty" json:"initial_backoff,omitempty"` // Delay before the first retry, doubled for each further one
+	MaxBackoff     string `yaml:"max_backoff,omitempty" json:"max_backoff,omitempty"`         // Upper bound for the retry delay
+	CircuitBreaker int    `yaml:"circuit_breaker,omitempty" json:"circuit_breaker,omitempty"` // Consecutive failures that suspend scheduled runs; 0 = never
+}
+
+// Validate checks that the backoff durations parse.
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 || p.CircuitBreaker < 0 {
+		return fmt.Errorf("max_attempts and circuit_breaker must not be negative")
+	}
+	for _, d := range []string{p.InitialBackoff, p.MaxBackoff} {
+		if d == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d); err != nil || v <= 0 {
+			return fmt.Errorf("invalid duration %q", d)
+		}
+	}
+	return nil
+}
+
+// Merge returns p with any non-zero fields of override applied on top.
+func (p RetryPolicy) Merge(override *RetryPolicy) RetryPolicy {
+	if override == nil {
+		return p
+	}
+	if override.MaxAttempts != 0 {
+		p.MaxAttempts = override.MaxAttempts
+	}
+	if override.InitialBackoff != "" {
+		p.InitialBackoff = override.InitialBackoff
+	}
+	if override.MaxBackoff != "" {
+		p.MaxBackoff = override.MaxBackoff
+	}
+	if override.CircuitBreaker != 0 {
+		p.CircuitBreaker = override.CircuitBreaker
+	}
+	return p
+}
+
+// BlackoutWindow is a daily time range during which scheduled indexing is not
+// allowed. Windows whose end is before their start span midnight.
+type BlackoutWindow struct {
+	Start string   `yaml:"start"`          // "HH:MM"
+	End   string   `yaml:"end"`            // "HH:MM"
+	Days  []string `yaml:"days,omitempty"` // "mon".."sun" the window starts on; empty = every day
+}
+
+// Precondition gates scheduled indexing on the presence or absence of a file,
+// e.g. a sentinel written while a parity check is running.
+type Precondition struct {
+	SentinelAbsent  string `yaml:"sentinel_absent,omitempty"`  // Block while this path exists
+	SentinelPresent string `yaml:"sentinel_present,omitempty"` // Block until this path exists
+}
+
+// ResourceLimits controls the CPU and I/O footprint of updatedb runs.
+type ResourceLimits struct {
+	Nice           int    `yaml:"nice,omitempty" json:"nice,omitempty"`                       // 1-19; 0 leaves the priority unchanged
+	IONiceClass    string `yaml:"ionice_class,omitempty" json:"ionice_class,omitempty"`       // "idle", "best-effort" or "realtime"
+	IONicePriority int    `yaml:"ionice_priority,omitempty" json:"ionice_priority,omitempty"` // 0-7, for best-effort and realtime
+	CPUMax         string `yaml:"cpu_max,omitempty" json:"cpu_max,omitempty"`                 // cgroup v2 cpu.max, e.g. "50000 100000" for half a CPU
+	IOMax          string `yaml:"io_max,omitempty" json:"io_max,omitempty"`                   // cgroup v2 io.max, e.g. "8:16 rbps=20971520"
+}
+
+// Validate checks that the limits are within the ranges nice and ionice accept.
+func (r ResourceLimits) Validate() error {
+	if r.Nice < 0 || r.Nice > 19 {
+		return fmt.Errorf("nice must be between 0 and 19, got %d", r.Nice)
+	}
+	switch r.IONiceClass {
+	case "", "idle", "best-effort", "realtime":
+	default:
+		return fmt.Errorf("ionice_class must be idle, best-effort or realtime, got %q", r.IONiceClass)
+	}
+	if r.IONicePriority < 0 || r.IONicePriority > 7 {
+		return fmt.Errorf("ionice_priority must be between 0 and 7, got %d", r.IONicePriority)
+	}
+	return nil
+}
+
+// Merge returns r with any non-zero fields of override applied on top.
+func (r ResourceLimits) Merge(override *ResourceLimits) ResourceLimits {
+	if override == nil {
+		return r
+	}
+	if override.Nice != 0 {
+		r.Nice = override.Nice
+	}
+	if override.IONiceClass != "" {
+		r.IONiceClass = override.IONiceClass
+		r.IONicePriority = override.IONicePriority
+	}
+	if override.CPUMax != "" {
+		r.CPUMax = override.CPUMax
+	}
+	if override.IOMax != "" {
+		r.IOMax = override.IOMax
+	}
+	return r
 }
 
 type Config struct {
-	Server struct {
-		Port string `yaml:"port"`
-	} `yaml:"server"`
+	Server ServerConfig `yaml:"server"`
+
+	// DataDir holds index databases created from the UI and other app state.
+	// Changing it moves managed databases and default state directories.
+	DataDir string `yaml:"data_dir"`
 
 	Plocate struct {
 		// Legacy fields for backward compatibility
@@ -35,7 +157,122 @@ type Config struct {
 	Scheduler struct {
 		Enabled  bool   `yaml:"enabled"`
 		Interval string `yaml:"interval"` // cron format: "0 */6 * * *" = every 6 hours
+
+		// Scheduled runs are deferred while a blackout window is open or a
+		// precondition fails, and start once indexing is allowed again.
+		BlackoutWindows []BlackoutWindow `yaml:"blackout_windows,omitempty"`
+		Preconditions   []Precondition   `yaml:"preconditions,omitempty"`
+		BlackoutAction  string           `yaml:"blackout_action,omitempty"`  // "defer" (default), "pause" or "stop" running builds
+		RecheckInterval string           `yaml:"recheck_interval,omitempty"` // How often deferred runs re-check, e.g. "1m"
 	} `yaml:"scheduler"`
+
+	// Limits on searches, each of which runs a plocate process. Rates are
+	// requests per second; a negative value turns a limit off.
+	Search struct {
+		RateLimit       float64 `yaml:"rate_limit"`        // Per client IP
+		RateBurst       int     `yaml:"rate_burst"`        // Requests a client may make at once before rate_limit applies
+		GlobalRateLimit float64 `yaml:"global_rate_limit"` // Across all clients
+		GlobalBurst     int     `yaml:"global_burst"`
+		MaxConcurrent   int     `yaml:"max_concurrent"` // plocate processes running at once
+		QueueTimeout    string  `yaml:"queue_timeout"`  // How long a search waits for a free slot before 429
+	} `yaml:"search"`
+
+	Indexing struct {
+		MaxConcurrent int    `yaml:"max_concurrent"` // 0 = unlimited
+		MaxPerDisk    int    `yaml:"max_per_disk"`   // 0 = unlimited
+		QueueOrder    string `yaml:"queue_order"`    // "fifo" or "priority"
+
+		Resources  ResourceLimits `yaml:"resources,omitempty"`
+		CgroupRoot string         `yaml:"cgroup_root,omitempty"` // Parent cgroup for cpu_max/io_max limits
+		MaxLoad    float64        `yaml:"max_load,omitempty"`    // Defer scheduled runs while 1-minute load average exceeds this; 0 = no guard
+		Retry      RetryPolicy    `yaml:"retry,omitempty"`       // Backoff and circuit breaker for failed builds; indices can override it
+	} `yaml:"indexing"`
+
+	BuildLogs struct {
+		Dir         string `yaml:"dir"`          // Per-run updatedb output is stored under <dir>/<index>/
+		Retain      int    `yaml:"retain"`       // Runs kept on disk per index
+		BufferLines int    `yaml:"buffer_lines"` // Lines of the latest run kept in memory
+	} `yaml:"build_logs"`
+
+	Notifications struct {
+		Webhooks       []WebhookConfig `yaml:"webhooks,omitempty"`
+		StaleAfter     string          `yaml:"stale_after,omitempty"`      // Warn when an enabled index has not built successfully for this long
+		MinFreePercent float64         `yaml:"min_free_percent,omitempty"` // Warn when a database filesystem has less free space; 0 = off
+		CheckInterval  string          `yaml:"check_interval,omitempty"`   // How often stale and disk-space checks run
+	} `yaml:"notifications"`
+
+	SavedSearches []SavedSearch `yaml:"saved_searches,omitempty"`
+
+	Auth struct {
+		SessionTTL string     `yaml:"session_ttl"` // How long a UI sign-in lasts
+		Users      []User     `yaml:"users,omitempty"`
+		Tokens     []APIToken `yaml:"tokens,omitempty"`
+		PathRules  []PathRule `yaml:"path_rules,omitempty"` // Hide result paths from matching users
+
+		ForwardAuth ForwardAuth `yaml:"forward_auth,omitempty"`
+	} `yaml:"auth"`
+
+	Changes struct {
+		Enabled  bool   `yaml:"enabled"`   // Diff each new database against the previous generation
+		Dir      string `yaml:"dir"`       // Change records are stored under <dir>/<index>/
+		Retain   int    `yaml:"retain"`    // Change records kept per index
+		MaxPaths int    `yaml:"max_paths"` // Paths stored per added/removed list; counts are always exact
+	} `yaml:"changes"`
+
+	Stats struct {
+		Enabled *bool `yaml:"enabled,omitempty"` // Compute entry, directory and extension stats after each build; default true
+	} `yaml:"stats,omitempty"`
+
+	Logging struct {
+		Level  string `yaml:"level"`  // debug, info, warn or error
+		Format string `yaml:"format"` // "logfmt" (key=value) or "json"
+	} `yaml:"logging"`
+
+	Audit struct {
+		Dir       string `yaml:"dir"`         // Mutating API calls are appended to <dir>/audit.log
+		MaxSizeMB int    `yaml:"max_size_mb"` // Rotate the log once it grows past this size
+		Retain    int    `yaml:"retain"`      // Rotated files kept next to the current log
+	} `yaml:"audit"`
+}
+
+// ServerConfig is how the HTTP server listens.
+type ServerConfig struct {
+	Port        string   `yaml:"port"`
+	Bind        string   `yaml:"bind,omitempty"`         // Address to listen on, e.g. "127.0.0.1"; empty = all interfaces
+	CORSOrigins []string `yaml:"cors_origins,omitempty"` // Origins allowed to call the API from a browser; empty = same origin only
+
+	// Durations; "0" means no limit
+	ReadTimeout       string `yaml:"read_timeout"`        // Reading a whole request, body included
+	ReadHeaderTimeout string `yaml:"read_header_timeout"` // Reading request headers
+	WriteTimeout      string `yaml:"write_timeout"`       // Writing a response; event and log streams are exempt
+	IdleTimeout       string `yaml:"idle_timeout"`        // Keep-alive connections waiting for the next request
+	MaxHeaderBytes    int    `yaml:"max_header_bytes"`
+
+	TLS TLSConfig `yaml:"tls,omitempty"`
+}
+
+// TLSConfig serves HTTPS from a certificate and key in PEM files. The files
+// are re-read when they change, so renewed certificates apply without a
+// restart.
+type TLSConfig struct {
+	CertFile   string `yaml:"cert_file,omitempty"`
+	KeyFile    string `yaml:"key_file,omitempty"`
+	SelfSigned bool   `yaml:"self_signed,omitempty"` // Generate a self-signed certificate if the files do not exist
+}
+
+// Enabled reports whether the server should serve HTTPS.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// WebhookConfig is a URL that receives JSON notifications.
+type WebhookConfig struct {
+	Name       string   `yaml:"name"`
+	URL        string   `yaml:"url"`
+	Secret     string   `yaml:"secret,omitempty"`      // Signs the body with HMAC-SHA256 in X-Plocate-Signature
+	Events     []string `yaml:"events,omitempty"`      // Event types to send; empty = all
+	MaxRetries int      `yaml:"max_retries,omitempty"` // Delivery attempts after the first failure; default 3, negative = none
+	Timeout    string   `yaml:"timeout,omitempty"`     // Per-request timeout, e.g. "10s"
 }
 
 var (
@@ -44,14 +281,64 @@ var (
 	mu         sync.Mutex
 )
 
-func Load(path string) error {
+// resolvePath returns path, or $CONFIG_PATH or the default location when it
+// is empty.
+func resolvePath(path string) string {
 	if path == "" {
 		path = os.Getenv("CONFIG_PATH")
 		if path == "" {
 			path = "/app/config/config.yml"
 		}
 	}
-	configPath = path
+	return path
+}
+
+// ReadServerConfig reads only the server settings, with the PORT override and
+// defaults applied, without migrating or writing anything. It lets a health
+// probe find the running server.
+func ReadServerConfig(path string) (ServerConfig, error) {
+	var cfg Config
+	data, err := os.ReadFile(resolvePath(path))
+	if err != nil && !os.IsNotExist(err) {
+		return ServerConfig{}, fmt.Errorf("failed to read config file: %w", err)
+	} else if err == nil {
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return ServerConfig{}, fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
+
+	if port := os.Getenv("PORT"); port != "" {
+		cfg.Server.Port = port
+	}
+	if cfg.Server.Port == "" {
+		cfg.Server.Port = "8080"
+	}
+	if err := validateServer(&cfg); err != nil {
+		return ServerConfig{}, err
+	}
+	return cfg.Server, nil
+}
+
+// CheckWritable reports whether the config file can be saved.
+func CheckWritable() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	f, err := os.OpenFile(configPath, os.O_WRONLY, 0)
+	if os.IsNotExist(err) {
+		f, err = os.CreateTemp(filepath.Dir(configPath), ".write-check-*")
+		if err == nil {
+			defer os.Remove(f.Name())
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("config file is not writable: %w", err)
+	}
+	return f.Close()
+}
+
+func Load(path string) error {
+	configPath = resolvePath(path)
 
 	var cfg Config
 
@@ -67,21 +354,55 @@ func Load(path string) error {
 		}
 	}
 
+	previousDataDir := cfg.DataDir
+
 	// Apply environment variable overrides
+	if dataDir := os.Getenv("DATA_DIR"); dataDir != "" {
+		cfg.DataDir = dataDir
+	}
 	if port := os.Getenv("PORT"); port != "" {
 		cfg.Server.Port = port
 	}
+	if origins := os.Getenv("CORS_ORIGINS"); origins != "" {
+		cfg.Server.CORSOrigins = strings.Split(origins, ",")
+	}
 	if dbPath := os.Getenv("PLOCATE_DB_PATH"); dbPath != "" {
 		cfg.Plocate.DatabasePath = dbPath
 	}
 	if interval := os.Getenv("INDEX_INTERVAL"); interval != "" {
 		cfg.Scheduler.Interval = interval
 	}
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		cfg.Logging.Level = level
+	}
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		cfg.Logging.Format = format
+	}
 
 	// Set defaults
 	if cfg.Server.Port == "" {
 		cfg.Server.Port = "8080"
 	}
+	for i, origin := range cfg.Server.CORSOrigins {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return fmt.Errorf("invalid server.cors_origins entry %q: must be \"*\" or start with http:// or https://", origin)
+		}
+		cfg.Server.CORSOrigins[i] = origin
+	}
+	if cfg.DataDir == "" {
+		cfg.DataDir = defaultDataDir
+	}
+	if !filepath.IsAbs(cfg.DataDir) {
+		return fmt.Errorf("data_dir must be an absolute path, got %q", cfg.DataDir)
+	}
+	cfg.DataDir = filepath.Clean(cfg.DataDir)
+	if err := validateServer(&cfg); err != nil {
+		return err
+	}
+	if err := validateLogging(&cfg); err != nil {
+		return err
+	}
 	if cfg.Plocate.UpdatedbBin == "" {
 		cfg.Plocate.UpdatedbBin = "updatedb"
 	}
@@ -91,6 +412,96 @@ func Load(path string) error {
 	if cfg.Scheduler.Interval == "" {
 		cfg.Scheduler.Interval = "0 */6 * * *" // Every 6 hours by default
 	}
+	if cfg.Indexing.QueueOrder == "" {
+		cfg.Indexing.QueueOrder = "fifo"
+	}
+	if err := validateSearchLimits(&cfg); err != nil {
+		return err
+	}
+	if cfg.Indexing.QueueOrder != "fifo" && cfg.Indexing.QueueOrder != "priority" {
+		return fmt.Errorf("invalid indexing.queue_order %q: must be \"fifo\" or \"priority\"", cfg.Indexing.QueueOrder)
+	}
+	if cfg.Scheduler.BlackoutAction == "" {
+		cfg.Scheduler.BlackoutAction = "defer"
+	}
+	if cfg.Scheduler.RecheckInterval == "" {
+		cfg.Scheduler.RecheckInterval = "1m"
+	}
+	if err := validateScheduler(&cfg); err != nil {
+		return err
+	}
+	if cfg.Indexing.CgroupRoot == "" {
+		cfg.Indexing.CgroupRoot = "/sys/fs/cgroup/plocate-ui"
+	}
+	if err := cfg.Indexing.Resources.Validate(); err != nil {
+		return fmt.Errorf("invalid indexing.resources: %w", err)
+	}
+	if cfg.BuildLogs.Dir == "" {
+		cfg.BuildLogs.Dir = filepath.Join(cfg.DataDir, "logs")
+	}
+	if cfg.BuildLogs.Retain <= 0 {
+		cfg.BuildLogs.Retain = 10
+	}
+	if cfg.BuildLogs.BufferLines <= 0 {
+		cfg.BuildLogs.BufferLines = 1000
+	}
+	if cfg.Changes.Dir == "" {
+		cfg.Changes.Dir = filepath.Join(cfg.DataDir, "changes")
+	}
+	if cfg.Changes.Retain <= 0 {
+		cfg.Changes.Retain = 30
+	}
+	if cfg.Changes.MaxPaths <= 0 {
+		cfg.Changes.MaxPaths = 10000
+	}
+	if cfg.Audit.Dir == "" {
+		cfg.Audit.Dir = filepath.Join(cfg.DataDir, "audit")
+	}
+	if cfg.Audit.MaxSizeMB <= 0 {
+		cfg.Audit.MaxSizeMB = 10
+	}
+	if cfg.Audit.Retain <= 0 {
+		cfg.Audit.Retain = 5
+	}
+	if cfg.Notifications.CheckInterval == "" {
+		cfg.Notifications.CheckInterval = "15m"
+	}
+	if cfg.Auth.SessionTTL == "" {
+		cfg.Auth.SessionTTL = "24h"
+	}
+	if err := validateAuth(&cfg); err != nil {
+		return err
+	}
+	if err := validatePathRules(&cfg); err != nil {
+		return err
+	}
+	if err := validateForwardAuth(&cfg); err != nil {
+		return err
+	}
+	if err := validateNotifications(&cfg); err != nil {
+		return err
+	}
+	if cfg.Indexing.Retry.InitialBackoff == "" {
+		cfg.Indexing.Retry.InitialBackoff = "1m"
+	}
+	if cfg.Indexing.Retry.MaxBackoff == "" {
+		cfg.Indexing.Retry.MaxBackoff = "1h"
+	}
+	if err := cfg.Indexing.Retry.Validate(); err != nil {
+		return fmt.Errorf("invalid indexing.retry: %w", err)
+	}
+	for _, index := range cfg.Plocate.Indices {
+		if index.Resources != nil {
+			if err := index.Resources.Validate(); err != nil {
+				return fmt.Errorf("invalid resources for index %s: %w", index.Name, err)
+			}
+		}
+		if index.Retry != nil {
+			if err := index.Retry.Validate(); err != nil {
+				return fmt.Errorf("invalid retry for index %s: %w", index.Name, err)
+			}
+		}
+	}
 
 	// Handle backward compatibility: convert old format to new format
 	if len(cfg.Plocate.Indices) == 0 && cfg.Plocate.DatabasePath != "" {
@@ -106,6 +517,17 @@ func Load(path string) error {
 		cfg.Plocate.Indices = []IndexConfig{defaultIndex}
 	}
 
+	if err := checkIndexNames(cfg.Plocate.Indices); err != nil {
+		return err
+	}
+	migrated, migrateErr := migrateDataDir(&cfg, previousDataDir)
+	if migrateErr != nil {
+		return migrateErr
+	}
+	if err := checkDatabasePaths(cfg.Plocate.Indices); err != nil {
+		return err
+	}
+
 	// Ensure all index database directories exist
 	for _, index := range cfg.Plocate.Indices {
 		dbDir := filepath.Dir(index.DatabasePath)
@@ -116,8 +538,9 @@ func Load(path string) error {
 
 	AppConfig = &cfg
 
-	// Persist the config so it exists on disk for next startup
-	if os.IsNotExist(err) {
+	// Persist the config so it exists on disk for next startup, and so
+	// migrated database paths are not migrated again
+	if os.IsNotExist(err) || migrated {
 		if saveErr := Save(); saveErr != nil {
 			return fmt.Errorf("failed to save initial config: %w", saveErr)
 		}
@@ -129,18 +552,230 @@ func Load(path string) error {
 func defaultConfig() Config {
 	var cfg Config
 	cfg.Server.Port = "8080"
+	cfg.DataDir = defaultDataDir
+	validateServer(&cfg)
+	validateLogging(&cfg)
 	cfg.Plocate.UpdatedbBin = "updatedb"
 	cfg.Plocate.PlocateBin = "plocate"
 	cfg.Scheduler.Enabled = true
 	cfg.Scheduler.Interval = "0 */6 * * *"
+	cfg.Scheduler.BlackoutAction = "defer"
+	cfg.Scheduler.RecheckInterval = "1m"
+	cfg.Indexing.QueueOrder = "fifo"
+	validateSearchLimits(&cfg)
+	cfg.Indexing.CgroupRoot = "/sys/fs/cgroup/plocate-ui"
+	cfg.Indexing.Retry.InitialBackoff = "1m"
+	cfg.Indexing.Retry.MaxBackoff = "1h"
+	cfg.BuildLogs.Dir = filepath.Join(cfg.DataDir, "logs")
+	cfg.BuildLogs.Retain = 10
+	cfg.BuildLogs.BufferLines = 1000
+	cfg.Notifications.CheckInterval = "15m"
+	cfg.Auth.SessionTTL = "24h"
+	cfg.Changes.Dir = filepath.Join(cfg.DataDir, "changes")
+	cfg.Changes.Retain = 30
+	cfg.Changes.MaxPaths = 10000
+	cfg.Audit.Dir = filepath.Join(cfg.DataDir, "audit")
+	cfg.Audit.MaxSizeMB = 10
+	cfg.Audit.Retain = 5
 	return cfg
 }
 
+// validateServer fills in HTTP server defaults and checks the TLS settings. A
+// self-signed certificate without file names is kept in <data_dir>/tls.
+func validateServer(cfg *Config) error {
+	s := &cfg.Server
+	if s.ReadTimeout == "" {
+		s.ReadTimeout = "30s"
+	}
+	if s.ReadHeaderTimeout == "" {
+		s.ReadHeaderTimeout = "10s"
+	}
+	if s.WriteTimeout == "" {
+		s.WriteTimeout = "60s"
+	}
+	if s.IdleTimeout == "" {
+		s.IdleTimeout = "120s"
+	}
+	for name, value := range map[string]string{
+		"read_timeout":        s.ReadTimeout,
+		"read_header_timeout": s.ReadHeaderTimeout,
+		"write_timeout":       s.WriteTimeout,
+		"idle_timeout":        s.IdleTimeout,
+	} {
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			return fmt.Errorf("invalid server.%s %q", name, value)
+		}
+	}
+	if s.MaxHeaderBytes <= 0 {
+		s.MaxHeaderBytes = 1 << 20
+	}
+
+	if s.TLS.SelfSigned && s.TLS.CertFile == "" && s.TLS.KeyFile == "" {
+		s.TLS.CertFile = filepath.Join(cfg.DataDir, "tls", "cert.pem")
+		s.TLS.KeyFile = filepath.Join(cfg.DataDir, "tls", "key.pem")
+	}
+	if (s.TLS.CertFile == "") != (s.TLS.KeyFile == "") {
+		return fmt.Errorf("server.tls needs both cert_file and key_file")
+	}
+	return nil
+}
+
+// StatsEnabled reports whether index stats are computed.
+func (c *Config) StatsEnabled() bool {
+	return c.Stats.Enabled == nil || *c.Stats.Enabled
+}
+
+// validateLogging defaults to info-level logfmt output.
+func validateLogging(cfg *Config) error {
+	l := &cfg.Logging
+	l.Level = strings.ToLower(l.Level)
+	l.Format = strings.ToLower(l.Format)
+	if l.Level == "" {
+		l.Level = "info"
+	}
+	if l.Format == "" {
+		l.Format = "logfmt"
+	}
+	switch l.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("invalid logging.level %q: must be debug, info, warn or error", l.Level)
+	}
+	if l.Format != "logfmt" && l.Format != "json" {
+		return fmt.Errorf("invalid logging.format %q: must be \"logfmt\" or \"json\"", l.Format)
+	}
+	return nil
+}
+
+// validateSearchLimits fills in search limit defaults: 5 searches per second
+// per client, 50 overall, bursts of twice the rate and 4 concurrent plocate
+// processes waiting up to 5s for a slot.
+func validateSearchLimits(cfg *Config) error {
+	s := &cfg.Search
+	if s.RateLimit == 0 {
+		s.RateLimit = 5
+	}
+	if s.GlobalRateLimit == 0 {
+		s.GlobalRateLimit = 50
+	}
+	if s.RateBurst <= 0 {
+		s.RateBurst = max(1, int(2*s.RateLimit))
+	}
+	if s.GlobalBurst <= 0 {
+		s.GlobalBurst = max(1, int(2*s.GlobalRateLimit))
+	}
+	if s.MaxConcurrent == 0 {
+		s.MaxConcurrent = 4
+	}
+	if s.QueueTimeout == "" {
+		s.QueueTimeout = "5s"
+	}
+	if d, err := time.ParseDuration(s.QueueTimeout); err != nil || d < 0 {
+		return fmt.Errorf("invalid search.queue_timeout %q", s.QueueTimeout)
+	}
+	return nil
+}
+
+func validateScheduler(cfg *Config) error {
+	switch cfg.Scheduler.BlackoutAction {
+	case "defer", "pause", "stop":
+	default:
+		return fmt.Errorf("invalid scheduler.blackout_action %q: must be defer, pause or stop", cfg.Scheduler.BlackoutAction)
+	}
+
+	if d, err := time.ParseDuration(cfg.Scheduler.RecheckInterval); err != nil || d <= 0 {
+		return fmt.Errorf("invalid scheduler.recheck_interval %q", cfg.Scheduler.RecheckInterval)
+	}
+
+	for i, w := range cfg.Scheduler.BlackoutWindows {
+		if _, err := ParseClock(w.Start); err != nil {
+			return fmt.Errorf("invalid start in blackout window %d: %w", i+1, err)
+		}
+		if _, err := ParseClock(w.End); err != nil {
+			return fmt.Errorf("invalid end in blackout window %d: %w", i+1, err)
+		}
+		for _, day := range w.Days {
+			if _, err := ParseWeekday(day); err != nil {
+				return fmt.Errorf("invalid day in blackout window %d: %w", i+1, err)
+			}
+		}
+	}
+
+	for i, p := range cfg.Scheduler.Preconditions {
+		if p.SentinelAbsent == "" && p.SentinelPresent == "" {
+			return fmt.Errorf("precondition %d must set sentinel_absent or sentinel_present", i+1)
+		}
+	}
+
+	return nil
+}
+
+func validateNotifications(cfg *Config) error {
+	n := &cfg.Notifications
+
+	for _, d := range []string{n.StaleAfter, n.CheckInterval} {
+		if d == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d); err != nil || v <= 0 {
+			return fmt.Errorf("invalid notifications duration %q", d)
+		}
+	}
+
+	for i := range n.Webhooks {
+		hook := &n.Webhooks[i]
+		if hook.URL == "" {
+			return fmt.Errorf("webhook %d has no url", i+1)
+		}
+		if hook.Name == "" {
+			hook.Name = fmt.Sprintf("webhook-%d", i+1)
+		}
+		if hook.Timeout == "" {
+			hook.Timeout = "10s"
+		}
+		if v, err := time.ParseDuration(hook.Timeout); err != nil || v <= 0 {
+			return fmt.Errorf("invalid timeout %q for webhook %s", hook.Timeout, hook.Name)
+		}
+		if hook.MaxRetries == 0 {
+			hook.MaxRetries = 3
+		}
+	}
+
+	return nil
+}
+
+// ParseClock parses an "HH:MM" time of day into minutes since midnight.
+func ParseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ParseWeekday parses a three-letter day name such as "mon".
+func ParseWeekday(s string) (time.Weekday, error) {
+	days := map[string]time.Weekday{
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+		"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	}
+	day, ok := days[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("unknown day %q", s)
+	}
+	return day, nil
+}
+
 // Save persists the current AppConfig to the config file.
 func Save() error {
 	mu.Lock()
 	defer mu.Unlock()
 
+	return saveLocked()
+}
+
+// saveLocked writes AppConfig to the config file. Caller must hold mu.
+func saveLocked() error {
 	data, err := yaml.Marshal(AppConfig)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
@@ -151,9 +786,13 @@ func Save() error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	// The config holds password and token hashes, so keep it private
+	if err := os.WriteFile(configPath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
+	if err := os.Chmod(configPath, 0600); err != nil {
+		return fmt.Errorf("failed to restrict config file permissions: %w", err)
+	}
 
 	return nil
 }
@@ -163,6 +802,10 @@ func AddIndex(name string, paths []string) (*IndexConfig, error) {
 	mu.Lock()
 	defer mu.Unlock()
 
+	if err := ValidateIndexName(name); err != nil {
+		return nil, err
+	}
+
 	// Check for duplicate name
 	for _, idx := range AppConfig.Plocate.Indices {
 		if idx.Name == name {
@@ -170,13 +813,17 @@ func AddIndex(name string, paths []string) (*IndexConfig, error) {
 		}
 	}
 
-	dbPath := filepath.Join("/app/data", name+".db")
+	dbPath := managedDBPath(AppConfig.DataDir, name)
+	if err := checkDatabaseCollision(dbPath, ""); err != nil {
+		return nil, err
+	}
 
 	idx := IndexConfig{
 		Name:         name,
 		DatabasePath: dbPath,
 		IndexPaths:   paths,
 		Enabled:      true,
+		Managed:      true,
 	}
 
 	// Ensure database directory exists
@@ -186,17 +833,8 @@ func AddIndex(name string, paths []string) (*IndexConfig, error) {
 
 	AppConfig.Plocate.Indices = append(AppConfig.Plocate.Indices, idx)
 
-	// Save without holding mu (Save has its own lock) — release and re-save
-	// Actually, Save also locks mu, so we need to save inline here.
-	data, err := yaml.Marshal(AppConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal config: %w", err)
-	}
-	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
-		return nil, fmt.Errorf("failed to create config directory: %w", err)
-	}
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write config file: %w", err)
+	if err := saveLocked(); err != nil {
+		return nil, err
 	}
 
 	return &idx, nil
@@ -223,17 +861,92 @@ func RemoveIndex(name string) error {
 
 	AppConfig.Plocate.Indices = indices
 
-	// Save inline (same mutex)
-	data, err := yaml.Marshal(AppConfig)
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+	return saveLocked()
+}
+
+// IndexUpdate lists the fields of an index to change; nil fields are left as
+// they are.
+type IndexUpdate struct {
+	Name       *string
+	IndexPaths []string
+	Enabled    *bool
+	Priority   *int
+
+	AllowedUsers  []string
+	AllowedGroups []string
+}
+
+// FindIndex returns a copy of the named index configuration.
+func FindIndex(name string) (IndexConfig, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, idx := range AppConfig.Plocate.Indices {
+		if idx.Name == name {
+			return idx, true
+		}
 	}
-	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
+	return IndexConfig{}, false
+}
+
+// UpdateIndex applies an update to an index and persists it. A rename moves
+// the index to dbPath and is carried over to saved searches that name it.
+func UpdateIndex(name string, update IndexUpdate, dbPath string) (*IndexConfig, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	pos := -1
+	for i, idx := range AppConfig.Plocate.Indices {
+		if idx.Name == name {
+			pos = i
+		} else if update.Name != nil && idx.Name == *update.Name {
+			return nil, fmt.Errorf("index '%s' already exists", *update.Name)
+		}
 	}
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+	if pos < 0 {
+		return nil, fmt.Errorf("index '%s' not found", name)
 	}
 
-	return nil
+	idx := AppConfig.Plocate.Indices[pos]
+	if update.Name != nil && *update.Name != name {
+		if err := ValidateIndexName(*update.Name); err != nil {
+			return nil, err
+		}
+		if err := checkDatabaseCollision(dbPath, name); err != nil {
+			return nil, err
+		}
+		idx.Name = *update.Name
+		idx.DatabasePath = dbPath
+
+		for i, s := range AppConfig.SavedSearches {
+			for j, indexName := range s.Indices {
+				if indexName == name {
+					AppConfig.SavedSearches[i].Indices[j] = idx.Name
+				}
+			}
+		}
+	}
+	if update.IndexPaths != nil {
+		idx.IndexPaths = update.IndexPaths
+	}
+	if update.Enabled != nil {
+		idx.Enabled = *update.Enabled
+	}
+	if update.Priority != nil {
+		idx.Priority = *update.Priority
+	}
+	if update.AllowedUsers != nil {
+		idx.AllowedUsers = update.AllowedUsers
+	}
+	if update.AllowedGroups != nil {
+		idx.AllowedGroups = update.AllowedGroups
+	}
+
+	AppConfig.Plocate.Indices[pos] = idx
+
+	if err := saveLocked(); err != nil {
+		return nil, err
+	}
+
+	return &idx, nil
 }
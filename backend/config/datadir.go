@@ -0,0 +1,241 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultDataDir is where index databases and app state lived before the data
+// directory became configurable.
+const defaultDataDir = "/app/data"
+
+var indexNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidateIndexName checks that a name for a new or renamed index is safe to
+// use as a file name: 1-64 letters, digits, '.', '_' or '-', starting with a
+// letter or digit.
+func ValidateIndexName(name string) error {
+	if !indexNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid index name %q: use 1-64 letters, digits, '.', '_' or '-', starting with a letter or digit", name)
+	}
+	return nil
+}
+
+// checkIndexNames rejects names from the config file that are empty,
+// duplicated or could escape a directory when used in a path. It is looser
+// than ValidateIndexName so existing configs keep loading.
+func checkIndexNames(indices []IndexConfig) error {
+	seen := make(map[string]bool)
+	for _, index := range indices {
+		name := index.Name
+		if strings.TrimSpace(name) == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+			return fmt.Errorf("invalid index name %q", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate index name %q", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// managedDBPath is where an index created from the UI keeps its database.
+func managedDBPath(dataDir, name string) string {
+	return filepath.Join(dataDir, name+".db")
+}
+
+// RenamedDatabasePath returns the database path index moves to when renamed
+// to name: managed databases stay in the data directory, others keep their
+// directory.
+func RenamedDatabasePath(index IndexConfig, name string) string {
+	if index.Managed {
+		return managedDBPath(AppConfig.DataDir, name)
+	}
+	return filepath.Join(filepath.Dir(index.DatabasePath), name+".db")
+}
+
+// checkDatabaseCollision returns an error if dbPath is already used by an
+// index other than exclude. Caller must hold mu.
+func checkDatabaseCollision(dbPath, exclude string) error {
+	dbPath = filepath.Clean(dbPath)
+	for _, idx := range AppConfig.Plocate.Indices {
+		if idx.Name != exclude && filepath.Clean(idx.DatabasePath) == dbPath {
+			return fmt.Errorf("database %s is already used by index '%s'", dbPath, idx.Name)
+		}
+	}
+	return nil
+}
+
+// checkDatabasePaths rejects configs where two indices share a database.
+func checkDatabasePaths(indices []IndexConfig) error {
+	seen := make(map[string]string)
+	for _, index := range indices {
+		if index.DatabasePath == "" {
+			return fmt.Errorf("index %s has no database_path", index.Name)
+		}
+		dbPath := filepath.Clean(index.DatabasePath)
+		if other, ok := seen[dbPath]; ok {
+			return fmt.Errorf("indices %s and %s share database %s", other, index.Name, dbPath)
+		}
+		seen[dbPath] = index.Name
+	}
+	return nil
+}
+
+// migrateDataDir moves the databases of managed indices, and the build logs,
+// change records, audit log and saved search state that lived in a previous
+// data directory, into cfg.DataDir. previous is the data directory recorded in
+// the config file before overrides. It reports whether anything changed.
+func migrateDataDir(cfg *Config, previous string) (bool, error) {
+	oldDirs := map[string]bool{defaultDataDir: true}
+	if previous != "" {
+		oldDirs[filepath.Clean(previous)] = true
+	}
+
+	changed := false
+	for i := range cfg.Plocate.Indices {
+		index := &cfg.Plocate.Indices[i]
+		current := filepath.Clean(index.DatabasePath)
+
+		// Indices created before the managed flag existed are recognised by
+		// their database living at <data dir>/<name>.db.
+		if !index.Managed {
+			if !oldDirs[filepath.Dir(current)] || filepath.Base(current) != index.Name+".db" {
+				continue
+			}
+			index.Managed = true
+			changed = true
+		}
+
+		target := managedDBPath(cfg.DataDir, index.Name)
+		if current == target {
+			continue
+		}
+		if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+			return changed, fmt.Errorf("failed to create data directory: %w", err)
+		}
+		for _, suffix := range []string{"", ".prev"} {
+			if err := movePath(current+suffix, target+suffix); err != nil {
+				return changed, fmt.Errorf("failed to migrate database for index %s: %w", index.Name, err)
+			}
+		}
+		oldDirs[filepath.Dir(current)] = true
+		index.DatabasePath = target
+		changed = true
+	}
+
+	relocate := func(dir *string, name string) error {
+		for old := range oldDirs {
+			if filepath.Clean(*dir) != filepath.Join(old, name) || old == filepath.Clean(cfg.DataDir) {
+				continue
+			}
+			target := filepath.Join(cfg.DataDir, name)
+			if err := movePath(*dir, target); err != nil {
+				return fmt.Errorf("failed to migrate %s: %w", *dir, err)
+			}
+			*dir = target
+			changed = true
+		}
+		return nil
+	}
+	if err := relocate(&cfg.BuildLogs.Dir, "logs"); err != nil {
+		return changed, err
+	}
+	if err := relocate(&cfg.Changes.Dir, "changes"); err != nil {
+		return changed, err
+	}
+	if err := relocate(&cfg.Audit.Dir, "audit"); err != nil {
+		return changed, err
+	}
+
+	for old := range oldDirs {
+		if old == filepath.Clean(cfg.DataDir) {
+			continue
+		}
+		if err := movePath(filepath.Join(old, "saved_searches.json"), filepath.Join(cfg.DataDir, "saved_searches.json")); err != nil {
+			return changed, fmt.Errorf("failed to migrate saved search state: %w", err)
+		}
+	}
+
+	return changed, nil
+}
+
+// movePath moves a file or directory, copying when src and dst are on
+// different filesystems. A missing src is not an error; an existing dst is.
+func movePath(src, dst string) error {
+	info, err := os.Stat(src)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	if info.IsDir() {
+		err = copyTree(src, dst)
+	} else {
+		err = copyFile(src, dst, info.Mode())
+	}
+	if err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(path, target, info.Mode())
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+)
+
+// SavedSearch is a named query that can be run on demand and, when watched,
+// is re-evaluated after each index build to detect new matches.
+type SavedSearch struct {
+	Name    string   `yaml:"name" json:"name"`
+	Query   string   `yaml:"query" json:"query"`
+	Indices []string `yaml:"indices,omitempty" json:"indices,omitempty"` // Empty = all enabled indices
+	Limit   int      `yaml:"limit,omitempty" json:"limit,omitempty"`
+	Watch   bool     `yaml:"watch" json:"watch"`
+}
+
+// FindSavedSearch returns a copy of the named saved search.
+func FindSavedSearch(name string) (SavedSearch, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, s := range AppConfig.SavedSearches {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return SavedSearch{}, false
+}
+
+// AddSavedSearch adds a saved search to the config and persists it.
+func AddSavedSearch(search SavedSearch) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, s := range AppConfig.SavedSearches {
+		if s.Name == search.Name {
+			return fmt.Errorf("saved search '%s' already exists", search.Name)
+		}
+	}
+
+	AppConfig.SavedSearches = append(AppConfig.SavedSearches, search)
+	return saveLocked()
+}
+
+// RemoveSavedSearch removes a saved search from the config and persists it.
+func RemoveSavedSearch(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	found := false
+	searches := make([]SavedSearch, 0, len(AppConfig.SavedSearches))
+	for _, s := range AppConfig.SavedSearches {
+		if s.Name == name {
+			found = true
+			continue
+		}
+		searches = append(searches, s)
+	}
+
+	if !found {
+		return fmt.Errorf("saved search '%s' not found", name)
+	}
+
+	AppConfig.SavedSearches = searches
+	return saveLocked()
+}
+
+// SavedSearches returns a copy of all saved searches.
+func SavedSearches() []SavedSearch {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return append([]SavedSearch{}, AppConfig.SavedSearches...)
+}
@@ -0,0 +1,181 @@
+package fsinfo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// Mount is a mounted filesystem that can hold indexable files.
+type Mount struct {
+	Path   string `json:"path"`
+	FSType string `json:"fs_type"`
+	Source string `json:"source"`
+}
+
+// Entry is a subdirectory returned by Browse.
+type Entry struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// pseudoFS are filesystem types that never hold user files.
+var pseudoFS = map[string]bool{
+	"proc": true, "sysfs": true, "cgroup": true, "cgroup2": true, "devpts": true,
+	"mqueue": true, "tmpfs": true, "devtmpfs": true, "overlay": true, "securityfs": true,
+	"debugfs": true, "tracefs": true, "pstore": true, "bpf": true, "hugetlbfs": true,
+	"configfs": true, "fusectl": true, "binfmt_misc": true, "autofs": true, "nsfs": true,
+	"rpc_pipefs": true, "shm": true,
+}
+
+// systemDirs are container paths that are mounted but not user data.
+var systemDirs = []string{"/proc", "/sys", "/dev", "/etc", "/run", "/app"}
+
+// Mounts lists the mounted volumes that can be indexed: real filesystems
+// mounted on a directory, excluding the container root and system paths.
+func Mounts() ([]Mount, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mounts: %w", err)
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	mounts := []Mount{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Format: id parent major:minor root mountpoint options ... - fstype source superoptions
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i, field := range fields {
+			if field == "-" {
+				sep = i
+				break
+			}
+		}
+		if len(fields) < 5 || sep < 0 || sep+2 >= len(fields) {
+			continue
+		}
+
+		path := unescapeMountPath(fields[4])
+		fsType, source := fields[sep+1], fields[sep+2]
+		if pseudoFS[fsType] || path == "/" || isSystemPath(path) || seen[path] {
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			continue
+		}
+
+		seen[path] = true
+		mounts = append(mounts, Mount{Path: path, FSType: fsType, Source: source})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mounts: %w", err)
+	}
+
+	sort.Slice(mounts, func(a, b int) bool { return mounts[a].Path < mounts[b].Path })
+	return mounts, nil
+}
+
+// unescapeMountPath decodes the octal escapes used in mountinfo for spaces,
+// tabs, newlines and backslashes.
+func unescapeMountPath(s string) string {
+	r := strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+	return r.Replace(s)
+}
+
+func isSystemPath(path string) bool {
+	for _, dir := range systemDirs {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Within reports whether path is inside one of the mounts.
+func Within(path string, mounts []Mount) bool {
+	for _, m := range mounts {
+		if path == m.Path || strings.HasPrefix(path, strings.TrimSuffix(m.Path, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Browse lists the subdirectories of path, which must lie inside a mounted
+// volume.
+func Browse(path string) ([]Entry, error) {
+	path = filepath.Clean(path)
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("path must be absolute")
+	}
+
+	mounts, err := Mounts()
+	if err != nil {
+		return nil, err
+	}
+	if !Within(path, mounts) {
+		return nil, fmt.Errorf("'%s' is not inside a mounted volume", path)
+	}
+
+	dirEntries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", path, err)
+	}
+
+	entries := []Entry{}
+	for _, e := range dirEntries {
+		full := filepath.Join(path, e.Name())
+		// Follow symlinks so linked shares can be picked too
+		if info, err := os.Stat(full); err == nil && info.IsDir() {
+			entries = append(entries, Entry{Name: e.Name(), Path: full})
+		}
+	}
+	return entries, nil
+}
+
+// ValidateIndexPath checks that path is an absolute, existing, readable
+// directory.
+func ValidateIndexPath(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("'%s' is not an absolute path", path)
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("'%s' does not exist (is it mounted in the container?)", path)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot access '%s': %w", path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", path)
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("'%s' is not readable: %w", path, err)
+	}
+	defer dir.Close()
+	if _, err := dir.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("'%s' is not readable: %w", path, err)
+	}
+
+	return nil
+}
+
+// Device returns the ID of the filesystem holding path.
+func Device(path string) (uint64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"plocate-ui/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseSince accepts an RFC 3339 timestamp, or a duration such as "24h"
+// meaning that long ago.
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("must be an RFC 3339 time or a duration like 24h")
+}
+
+// GetAuditLog returns recorded mutating API calls, newest first. Filters:
+// ?actor=, ?ip=, ?method=, ?path= (substring), ?outcome=, ?since= and ?until=
+// (RFC 3339 or a duration ago) and ?limit= (default 100, at most 1000).
+func GetAuditLog(c *gin.Context) {
+	filter := audit.Filter{
+		Actor:    c.Query("actor"),
+		ClientIP: c.Query("ip"),
+		Method:   c.Query("method"),
+		Path:     c.Query("path"),
+		Outcome:  c.Query("outcome"),
+		Limit:    100,
+	}
+
+	switch filter.Outcome {
+	case "", audit.OutcomeSuccess, audit.OutcomeDenied, audit.OutcomeFailure:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "outcome must be success, denied or failure"})
+		return
+	}
+	for param, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if s := c.Query(param); s != "" {
+			parsed, err := parseSince(s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " " + err.Error()})
+				return
+			}
+			*t = parsed
+		}
+	}
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := audit.Query(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"plocate-ui/auth"
+	"plocate-ui/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CredentialsRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// AuthStatus reports whether first-run setup is needed and who, if anyone,
+// the request is signed in as.
+func AuthStatus(c *gin.Context) {
+	if auth.SetupRequired() {
+		c.JSON(http.StatusOK, gin.H{"setup_required": true, "authenticated": false})
+		return
+	}
+
+	id, method, ok := auth.Resolve(c)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"setup_required": false, "authenticated": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"setup_required": false,
+		"authenticated":  true,
+		"username":       id.Username,
+		"role":           id.Role,
+		"groups":         id.Groups,
+		"method":         method,
+	})
+}
+
+// Setup creates the first user as an admin and signs them in. It is only
+// available while no user exists and forward auth is off.
+func Setup(c *gin.Context) {
+	if !auth.SetupRequired() {
+		c.JSON(http.StatusConflict, gin.H{"error": "setup is not available"})
+		return
+	}
+
+	var req CredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := newUser(req, config.RoleAdmin)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.SetupUser(user); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	auth.StartSession(c, user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": "setup complete", "username": user.Username})
+}
+
+func Login(c *gin.Context) {
+	var req CredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !auth.Authenticate(req.Username, req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
+		return
+	}
+
+	auth.StartSession(c, req.Username)
+	c.JSON(http.StatusOK, gin.H{"message": "signed in", "username": req.Username})
+}
+
+func Logout(c *gin.Context) {
+	auth.EndSession(c)
+	c.JSON(http.StatusOK, gin.H{"message": "signed out"})
+}
+
+func newUser(req CredentialsRequest, role string) (config.User, error) {
+	username := strings.TrimSpace(req.Username)
+	if err := config.ValidateUsername(username); err != nil {
+		return config.User{}, err
+	}
+	if err := config.ValidateRole(role); err != nil {
+		return config.User{}, err
+	}
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return config.User{}, err
+	}
+	return config.User{Username: username, PasswordHash: hash, Role: role}, nil
+}
+
+func ListUsers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"users": config.Users()})
+}
+
+type AddUserRequest struct {
+	CredentialsRequest
+	Role   string   `json:"role"` // Defaults to viewer
+	Groups []string `json:"groups"`
+}
+
+func AddUser(c *gin.Context) {
+	var req AddUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role == "" {
+		req.Role = config.RoleViewer
+	}
+
+	user, err := newUser(req.CredentialsRequest, req.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user.Groups = req.Groups
+
+	if err := config.AddUser(user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user added", "user": user})
+}
+
+type UpdateUserRequest struct {
+	Role   string   `json:"role"`   // Unchanged if empty
+	Groups []string `json:"groups"` // Unchanged if omitted
+}
+
+// UpdateUser changes a user's role or groups.
+func UpdateUser(c *gin.Context) {
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	username := c.Param("username")
+	if req.Role == "" {
+		current, ok := config.FindUser(username)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user '" + username + "' not found"})
+			return
+		}
+		req.Role = current.Role
+	}
+	if err := config.ValidateRole(req.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := config.UpdateUser(username, req.Role, req.Groups)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user updated", "user": user})
+}
+
+func RemoveUser(c *gin.Context) {
+	username := c.Param("username")
+
+	if err := config.RemoveUser(username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	auth.EndUserSessions(username, "")
+	c.JSON(http.StatusOK, gin.H{"message": "user removed"})
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword changes the signed-in user's password and signs out their
+// other sessions.
+func ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	username := auth.Username(c)
+	if !auth.Authenticate(username, req.CurrentPassword) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "current password is incorrect"})
+		return
+	}
+
+	hash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.SetPasswordHash(username, hash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	auth.EndUserSessions(username, auth.SessionID(c))
+	c.JSON(http.StatusOK, gin.H{"message": "password changed"})
+}
+
+// ListAPITokens returns the signed-in user's API tokens, or every token for
+// admins.
+func ListAPITokens(c *gin.Context) {
+	tokens := []config.APIToken{}
+	for _, t := range config.APITokens() {
+		if t.Username == auth.Username(c) || auth.Role(c) == config.RoleAdmin {
+			tokens = append(tokens, t)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+type CreateAPITokenRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// CreateAPIToken issues a bearer token for the signed-in user, with their
+// role. The token is only returned in this response.
+func CreateAPIToken(c *gin.Context) {
+	var req CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	// Tokens act as a local account, so proxy-only users cannot create them
+	if _, ok := config.FindUser(auth.Username(c)); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API tokens require a local account"})
+		return
+	}
+
+	token, stored := auth.NewAPIToken(name, auth.Username(c))
+	if err := config.AddAPIToken(stored); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "api_token": stored})
+}
+
+// RevokeAPIToken removes an API token. Users other than admins can only revoke
+// their own tokens.
+func RevokeAPIToken(c *gin.Context) {
+	name := c.Param("name")
+	if auth.Role(c) != config.RoleAdmin {
+		owned := false
+		for _, t := range config.APITokens() {
+			owned = owned || (t.Name == name && t.Username == auth.Username(c))
+		}
+		if !owned {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API token '" + name + "' not found"})
+			return
+		}
+	}
+
+	if err := config.RemoveAPIToken(name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API token revoked"})
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"plocate-ui/auth"
+	"plocate-ui/indexer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetChanges returns the paths added and removed by recent rebuilds of an
+// index. ?since= accepts an RFC 3339 timestamp or a duration such as "24h";
+// without it all retained change records are returned.
+func GetChanges(c *gin.Context) {
+	indexName := c.Param("indexName")
+
+	var since time.Time
+	if s := c.Query("since"); s != "" {
+		t, err := parseSince(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since " + err.Error()})
+			return
+		}
+		since = t
+	}
+
+	changes, err := indexer.Instance.Changes(indexName, since)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Paths hidden by path rules are dropped and not counted. The lists of a
+	// truncated change set don't hold every path, so only the hidden paths
+	// they do hold can be taken off its counts.
+	if id := auth.Identity(c); id.HasPathRules() {
+		for i := range changes {
+			cs := &changes[i]
+			added, removed := id.FilterPaths(cs.Added), id.FilterPaths(cs.Removed)
+			hidden := len(cs.Added) - len(added) + len(cs.Removed) - len(removed)
+			cs.AddedCount -= len(cs.Added) - len(added)
+			cs.RemovedCount -= len(cs.Removed) - len(removed)
+			cs.Added, cs.Removed = added, removed
+			cs.CountsUpperBound = cs.Truncated && hidden > 0
+		}
+	}
+
+	added, removed, upperBound := 0, 0, false
+	for _, cs := range changes {
+		added += cs.AddedCount
+		removed += cs.RemovedCount
+		upperBound = upperBound || cs.CountsUpperBound
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"index":              indexName,
+		"changes":            changes,
+		"added_total":        added,
+		"removed_total":      removed,
+		"totals_upper_bound": upperBound,
+	})
+}
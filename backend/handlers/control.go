@@ -4,8 +4,10 @@ import (
 	"net/http"
 	"strings"
 
+	"plocate-ui/auth"
 	"plocate-ui/config"
 	"plocate-ui/indexer"
+	"plocate-ui/logging"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,20 +17,20 @@ func StartIndexing(c *gin.Context) {
 
 	// If no index name specified, start all enabled indices
 	if indexName == "" {
-		if err := indexer.Instance.StartIndexingAll(); err != nil {
+		if err := indexer.Instance.StartIndexingAll(c.Request.Context()); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "indexing started for all enabled indices"})
+		c.JSON(http.StatusOK, gin.H{"message": "indexing started for all enabled indices", "request_id": logging.RequestID(c.Request.Context())})
 		return
 	}
 
-	if err := indexer.Instance.StartIndexing(indexName); err != nil {
+	if err := indexer.Instance.StartIndexing(c.Request.Context(), indexName); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "indexing started for " + indexName})
+	c.JSON(http.StatusOK, gin.H{"message": "indexing started for " + indexName, "request_id": logging.RequestID(c.Request.Context())})
 }
 
 func StopIndexing(c *gin.Context) {
@@ -52,8 +54,15 @@ func StopIndexing(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "indexing stopped for " + indexName})
 }
 
+// GetIndices lists the indices the user may search.
 func GetIndices(c *gin.Context) {
-	indices := indexer.Instance.GetIndexNames()
+	id := auth.Identity(c)
+	indices := []string{}
+	for _, name := range indexer.Instance.GetIndexNames() {
+		if id.CanSearchIndex(name) {
+			indices = append(indices, name)
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"indices": indices})
 }
 
@@ -84,6 +93,10 @@ func AddIndex(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
 		return
 	}
+	if err := config.ValidateIndexName(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if len(req.IndexPaths) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one index path is required"})
@@ -103,6 +116,12 @@ func AddIndex(c *gin.Context) {
 		return
 	}
 
+	paths, warnings, err := validateIndexPaths(paths, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Add to config (persists to disk)
 	idx, err := config.AddIndex(name, paths)
 	if err != nil {
@@ -113,7 +132,7 @@ func AddIndex(c *gin.Context) {
 	// Register in running indexer
 	indexer.Instance.AddIndex(*idx)
 
-	c.JSON(http.StatusOK, gin.H{"message": "index added", "index": idx})
+	c.JSON(http.StatusOK, gin.H{"message": "index added", "index": idx, "warnings": warnings})
 }
 
 func RemoveIndex(c *gin.Context) {
@@ -123,6 +142,16 @@ func RemoveIndex(c *gin.Context) {
 		return
 	}
 
+	purge := c.Query("purge") == "true"
+	indexCfg, found := config.FindIndex(indexName)
+	if purge && found {
+		// A stopped build may still be writing its new database
+		if status, err := indexer.Instance.GetIndexStatus(indexName); err == nil && status.IsIndexing {
+			c.JSON(http.StatusConflict, gin.H{"error": "index '" + indexName + "' is being indexed; stop it before purging"})
+			return
+		}
+	}
+
 	// Remove from running indexer first (stops if running)
 	if err := indexer.Instance.RemoveIndex(indexName); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -135,5 +164,134 @@ func RemoveIndex(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "index removed"})
+	if !purge {
+		c.JSON(http.StatusOK, gin.H{"message": "index removed"})
+		return
+	}
+
+	removed, skipped, err := indexer.PurgeIndexFiles(indexCfg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "index removed but purge failed: " + err.Error(), "purged": removed, "skipped": skipped})
+		return
+	}
+
+	resp := gin.H{"message": "index removed", "purged": removed}
+	if len(skipped) > 0 {
+		// Only databases created by this app are deleted
+		resp["skipped"] = skipped
+		resp["message"] = "index removed; its database is outside the data directory and was kept"
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func RollbackIndex(c *gin.Context) {
+	indexName := c.Param("indexName")
+
+	if err := indexer.Instance.RestorePrevious(indexName); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "previous database restored for " + indexName})
+}
+
+func AcknowledgeFailures(c *gin.Context) {
+	indexName := c.Param("indexName")
+
+	if err := indexer.Instance.AcknowledgeFailures(indexName); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "failures acknowledged for " + indexName})
+}
+
+func GetIndex(c *gin.Context) {
+	indexName := c.Param("indexName")
+
+	idx, ok := config.FindIndex(indexName)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "index '" + indexName + "' not found"})
+		return
+	}
+
+	status, err := indexer.Instance.GetIndexStatus(indexName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"index": idx, "status": status})
+}
+
+type UpdateIndexRequest struct {
+	Name       *string  `json:"name"`
+	IndexPaths []string `json:"index_paths"`
+	Enabled    *bool    `json:"enabled"`
+	Priority   *int     `json:"priority"`
+
+	AllowedUsers  []string `json:"allowed_users"`  // [] opens the index to everyone
+	AllowedGroups []string `json:"allowed_groups"` // [] opens the index to everyone
+}
+
+func UpdateIndex(c *gin.Context) {
+	indexName := c.Param("indexName")
+
+	var req UpdateIndexRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	update := config.IndexUpdate{
+		Enabled:       req.Enabled,
+		Priority:      req.Priority,
+		AllowedUsers:  req.AllowedUsers,
+		AllowedGroups: req.AllowedGroups,
+	}
+	warnings := []string{}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+			return
+		}
+		if err := config.ValidateIndexName(name); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		update.Name = &name
+	}
+
+	if req.IndexPaths != nil {
+		// Trim whitespace from paths and filter empty
+		paths := []string{}
+		for _, p := range req.IndexPaths {
+			p = strings.TrimSpace(p)
+			if p != "" {
+				paths = append(paths, p)
+			}
+		}
+		if len(paths) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at least one non-empty index path is required"})
+			return
+		}
+
+		paths, pathWarnings, err := validateIndexPaths(paths, indexName)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		update.IndexPaths = paths
+		warnings = pathWarnings
+	}
+
+	idx, err := indexer.Instance.UpdateIndex(indexName, update)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "index updated", "index": idx, "warnings": warnings})
 }
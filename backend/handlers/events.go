@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"plocate-ui/auth"
+	"plocate-ui/config"
+	"plocate-ui/indexer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventHeartbeat keeps idle event streams open through proxies.
+const eventHeartbeat = 30 * time.Second
+
+// keepStreamOpen lifts the server's write timeout for a long-lived stream.
+func keepStreamOpen(c *gin.Context) {
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+}
+
+// StreamEvents pushes indexer events to the client as server-sent events. The
+// SSE event name is the event type and the data is the JSON-encoded event. A
+// "status" event with the full status is sent first so clients start in sync.
+// Events and status are limited to the indices and paths the user may see.
+func StreamEvents(c *gin.Context) {
+	id := auth.Identity(c)
+	events, stop := indexer.Instance.Subscribe()
+	defer stop()
+
+	keepStreamOpen(c)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.SSEvent("status", visibleStatus(id, indexer.Instance.GetStatus()))
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			if event, ok := visibleEvent(id, event); ok {
+				c.SSEvent(event.Type, event)
+			}
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", time.Now())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// visibleEvent reports whether id may see event, dropping events about
+// indices it may not search. Saved search matches come from unrestricted
+// runs, so their paths are filtered and the event is dropped if none remain.
+// Build progress lines are raw updatedb output that may name any path, so
+// users restricted by path rules don't get them.
+func visibleEvent(id *config.Identity, event indexer.Event) (indexer.Event, bool) {
+	if !event.VisibleTo(id) {
+		return event, false
+	}
+	if event.Type == indexer.EventBuildProgress && id.HasPathRules() {
+		return event, false
+	}
+	if event.Type == indexer.EventSavedSearch {
+		paths := id.FilterResults(event.Paths)
+		if len(paths) == 0 {
+			return event, false
+		}
+		if len(paths) < len(event.Paths) {
+			event.Message = fmt.Sprintf("%d new matches for saved search %s", len(paths), event.Search)
+		}
+		event.Paths = paths
+	}
+	return event, true
+}
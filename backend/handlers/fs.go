@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"plocate-ui/config"
+	"plocate-ui/fsinfo"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListMounts(c *gin.Context) {
+	mounts, err := fsinfo.Mounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mounts": mounts})
+}
+
+func BrowseDirectory(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path parameter is required"})
+		return
+	}
+
+	entries, err := fsinfo.Browse(path)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"path": filepath.Clean(path), "entries": entries})
+}
+
+// validateIndexPaths cleans and checks the paths of an index. It returns an
+// error for paths that cannot be indexed and warnings for paths on the same
+// filesystem as another index (other than the one named exclude).
+func validateIndexPaths(paths []string, exclude string) ([]string, []string, error) {
+	cleaned := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		if err := fsinfo.ValidateIndexPath(p); err != nil {
+			return nil, nil, err
+		}
+		cleaned = append(cleaned, p)
+	}
+
+	// Map filesystems used by other indices to their names
+	others := make(map[uint64][]string)
+	for _, idx := range config.AppConfig.Plocate.Indices {
+		if idx.Name == exclude {
+			continue
+		}
+		for _, p := range idx.IndexPaths {
+			if dev, err := fsinfo.Device(p); err == nil {
+				others[dev] = append(others[dev], idx.Name)
+			}
+		}
+	}
+
+	warnings := []string{}
+	for _, p := range cleaned {
+		dev, err := fsinfo.Device(p)
+		if err != nil {
+			continue
+		}
+		if names, ok := others[dev]; ok {
+			warnings = append(warnings, fmt.Sprintf("'%s' is on the same filesystem as index '%s'", p, names[0]))
+		}
+	}
+
+	return cleaned, warnings, nil
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+
+	"plocate-ui/config"
+	"plocate-ui/indexer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthCheck is the result of one check. These endpoints are reachable
+// without signing in, so messages name no paths or indices.
+type HealthCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+func checkBinary(name, bin string) HealthCheck {
+	if _, err := exec.LookPath(bin); err != nil {
+		return HealthCheck{Name: name, Message: "not found"}
+	}
+	return HealthCheck{Name: name, OK: true}
+}
+
+// livenessChecks must pass for the server to do anything useful.
+func livenessChecks() []HealthCheck {
+	cfg := config.AppConfig.Plocate
+	checks := []HealthCheck{
+		checkBinary("plocate", cfg.PlocateBin),
+		checkBinary("updatedb", cfg.UpdatedbBin),
+	}
+
+	writable := HealthCheck{Name: "config_writable", OK: true}
+	if config.CheckWritable() != nil {
+		writable = HealthCheck{Name: "config_writable", Message: "config file cannot be saved"}
+	}
+	return append(checks, writable)
+}
+
+// databaseCheck passes when every enabled index has a database, counting an
+// index whose first build is running as pending rather than missing.
+func databaseCheck() HealthCheck {
+	status := indexer.Instance.GetStatus()
+	building := make(map[string]bool)
+	for _, s := range status.Indices {
+		building[s.Name] = s.IsIndexing || s.IsQueued
+	}
+
+	missing, pending := 0, 0
+	for _, index := range config.AppConfig.Plocate.Indices {
+		if !index.Enabled {
+			continue
+		}
+		if _, err := os.Stat(index.DatabasePath); err == nil {
+			continue
+		}
+		if building[index.Name] {
+			pending++
+		} else {
+			missing++
+		}
+	}
+
+	switch {
+	case missing > 0:
+		return HealthCheck{Name: "databases", Message: fmt.Sprintf("%d enabled indices have no database", missing)}
+	case pending > 0:
+		return HealthCheck{Name: "databases", Message: fmt.Sprintf("%d enabled indices are building their first database", pending)}
+	}
+	return HealthCheck{Name: "databases", OK: true}
+}
+
+func healthResponse(c *gin.Context, checks []HealthCheck) {
+	code, status := http.StatusOK, "ok"
+	for _, check := range checks {
+		if !check.OK {
+			code, status = http.StatusServiceUnavailable, "unavailable"
+			break
+		}
+	}
+	c.JSON(code, gin.H{"status": status, "checks": checks})
+}
+
+// Healthz reports whether the server is alive: the plocate and updatedb
+// binaries are present and the config can be saved. Missing databases do not
+// fail it, so a fresh install is not restarted before its first build.
+func Healthz(c *gin.Context) {
+	healthResponse(c, livenessChecks())
+}
+
+// Readyz additionally requires a database for every enabled index, so
+// searches return complete results.
+func Readyz(c *gin.Context) {
+	healthResponse(c, append(livenessChecks(), databaseCheck()))
+}
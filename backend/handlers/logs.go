@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"plocate-ui/indexer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBuildLogs returns the output of the latest build of an index, or of a
+// stored run with ?run=<id>. With ?follow=true the latest run is streamed as
+// server-sent "line" events, ending with an "end" event when the build stops.
+func GetBuildLogs(c *gin.Context) {
+	indexName := c.Param("indexName")
+
+	latest, err := indexer.Instance.LatestBuildLog(indexName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	runs, err := indexer.BuildLogRuns(indexName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("follow") == "true" {
+		if latest == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no build has run for " + indexName})
+			return
+		}
+		followBuildLog(c, latest)
+		return
+	}
+
+	if run := c.Query("run"); run != "" && (latest == nil || run != latest.RunID) {
+		lines, err := indexer.ReadBuildLog(indexName, run)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"run": run, "running": false, "lines": lines, "runs": runs})
+		return
+	}
+
+	if latest == nil {
+		// Nothing built since startup; fall back to the newest stored run
+		if len(runs) == 0 {
+			c.JSON(http.StatusOK, gin.H{"run": "", "running": false, "lines": []string{}, "runs": runs})
+			return
+		}
+		lines, err := indexer.ReadBuildLog(indexName, runs[0])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"run": runs[0], "running": false, "lines": lines, "runs": runs})
+		return
+	}
+
+	snap := latest.Snapshot()
+	c.JSON(http.StatusOK, gin.H{"run": snap.RunID, "running": snap.Running, "lines": snap.Lines, "runs": runs})
+}
+
+func followBuildLog(c *gin.Context, buildLog *indexer.BuildLog) {
+	snap, lines, stop := buildLog.Follow()
+	defer stop()
+
+	keepStreamOpen(c)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	for _, line := range snap.Lines {
+		c.SSEvent("line", line)
+	}
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				c.SSEvent("end", snap.RunID)
+				return false
+			}
+			c.SSEvent("line", line)
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
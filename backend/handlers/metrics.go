@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"plocate-ui/auth"
+	"plocate-ui/config"
+	"plocate-ui/indexer"
+	"plocate-ui/metrics"
+	"plocate-ui/throttle"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searchIndexKey holds the index label Search chose for its request.
+const searchIndexKey = "metrics_search_index"
+
+// searchIndexLabel names the indices of a search for metrics, keeping the
+// number of series bounded: names that are not configured become "unknown".
+func searchIndexLabel(indices []string) string {
+	switch len(indices) {
+	case 0:
+		return "all"
+	case 1:
+		if _, ok := config.FindIndex(indices[0]); ok {
+			return indices[0]
+		}
+		return "unknown"
+	}
+	return "multiple"
+}
+
+// metricsIndexVisible reports whether id may see series labelled with index.
+// Besides the labels searchIndexLabel makes up, names that are no longer
+// configured, e.g. of a removed private index, are only shown to admins.
+func metricsIndexVisible(id *config.Identity, index string) bool {
+	if cfg, ok := config.FindIndex(index); ok {
+		return id.CanSearch(cfg)
+	}
+	switch index {
+	case "all", "multiple", "unknown":
+		return true
+	}
+	return id == nil || id.Role == config.RoleAdmin
+}
+
+// ObserveSearch records the status and latency of search requests, including
+// ones rejected by rate limits before Search runs. It must run before them.
+func ObserveSearch(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	index := c.GetString(searchIndexKey)
+	if index == "" {
+		index = "unknown"
+	}
+	metrics.SearchRequests.Inc(index, strconv.Itoa(c.Writer.Status()))
+	metrics.SearchDuration.Observe(time.Since(start).Seconds(), index)
+}
+
+// Metrics serves Prometheus metrics. Series for indices the caller may not
+// search are left out.
+func Metrics(c *gin.Context) {
+	id := auth.Identity(c)
+	status := indexer.Instance.GetStatus()
+	entries := indexer.Instance.CachedEntries()
+
+	var buf bytes.Buffer
+	metrics.Write(&buf, func(index string) bool { return metricsIndexVisible(id, index) })
+
+	var sizes, counts, lastSuccess []metrics.Sample
+	for _, s := range status.Indices {
+		if !id.CanSearchIndex(s.Name) {
+			continue
+		}
+		labels := []string{s.Name}
+		last := s.LastIndexed
+		if info, err := os.Stat(s.DatabasePath); err == nil {
+			sizes = append(sizes, metrics.Sample{LabelValues: labels, Value: float64(info.Size())})
+			// A database is only swapped in by a successful build
+			if last.IsZero() {
+				last = info.ModTime()
+			}
+		}
+		if n, ok := entries[s.Name]; ok {
+			counts = append(counts, metrics.Sample{LabelValues: labels, Value: float64(n)})
+		}
+		if !last.IsZero() {
+			lastSuccess = append(lastSuccess, metrics.Sample{LabelValues: labels, Value: float64(last.Unix())})
+		}
+	}
+	index := []string{metrics.IndexLabel}
+	metrics.WriteSamples(&buf, "plocate_ui_database_size_bytes", "Size of the current database of each index.", "gauge", index, sizes)
+	metrics.WriteSamples(&buf, "plocate_ui_index_entries", "Entries in the current database, once its stats have been computed.", "gauge", index, counts)
+	metrics.WriteSamples(&buf, "plocate_ui_last_success_timestamp_seconds", "Unix time of the last successful build; for builds before startup, when the database was written.", "gauge", index, lastSuccess)
+
+	if !status.NextScheduled.IsZero() {
+		metrics.WriteSamples(&buf, "plocate_ui_scheduler_next_run_timestamp_seconds", "Unix time of the next scheduled build.", "gauge", nil,
+			[]metrics.Sample{{Value: float64(status.NextScheduled.Unix())}})
+	}
+	metrics.WriteSamples(&buf, "plocate_ui_builds_running", "Builds currently running.", "gauge", nil,
+		[]metrics.Sample{{Value: float64(status.Running)}})
+	metrics.WriteSamples(&buf, "plocate_ui_builds_queued", "Builds waiting in the queue.", "gauge", nil,
+		[]metrics.Sample{{Value: float64(len(status.Queue))}})
+
+	var rejected []metrics.Sample
+	rejectedBy := throttle.GetStats().RejectedBy
+	for _, reason := range []string{throttle.ReasonClient, throttle.ReasonGlobal, throttle.ReasonBusy} {
+		rejected = append(rejected, metrics.Sample{LabelValues: []string{reason}, Value: float64(rejectedBy[reason])})
+	}
+	metrics.WriteSamples(&buf, "plocate_ui_search_rejected_total", "Searches rejected with 429 by rate limits or the concurrency cap.", "counter", []string{"reason"}, rejected)
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+
+	"plocate-ui/config"
+	"plocate-ui/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TestNotificationRequest struct {
+	Webhook string `json:"webhook"` // Optional: if empty, all webhooks are tested
+}
+
+func TestNotification(c *gin.Context) {
+	var req TestNotificationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if len(config.AppConfig.Notifications.Webhooks) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no webhooks configured"})
+		return
+	}
+
+	results, err := notify.SendTest(req.Webhook)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, r := range results {
+		if r.Error != "" {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "some webhooks failed", "results": results})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "test notification delivered", "results": results})
+}
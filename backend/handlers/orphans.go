@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+
+	"plocate-ui/indexer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListOrphans returns database files and history directories that no
+// configured index refers to.
+func ListOrphans(c *gin.Context) {
+	orphans, err := indexer.Orphans()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total int64
+	for _, orphan := range orphans {
+		total += orphan.Size
+	}
+
+	c.JSON(http.StatusOK, gin.H{"orphans": orphans, "total_size": total})
+}
+
+type ReclaimOrphansRequest struct {
+	Paths []string `json:"paths"` // Empty reclaims every orphan
+}
+
+// ReclaimOrphans deletes orphaned files and reports the space freed.
+func ReclaimOrphans(c *gin.Context) {
+	var req ReclaimOrphansRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	removed, err := indexer.ReclaimOrphans(req.Paths)
+
+	var freed int64
+	for _, orphan := range removed {
+		freed += orphan.Size
+	}
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "removed": removed, "freed": freed})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"removed": removed, "freed": freed})
+}
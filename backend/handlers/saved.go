@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"plocate-ui/auth"
+	"plocate-ui/config"
+	"plocate-ui/indexer"
+	"plocate-ui/saved"
+	"plocate-ui/throttle"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SavedSearchResponse struct {
+	config.SavedSearch
+	State *saved.State `json:"state,omitempty"`
+}
+
+func ListSavedSearches(c *gin.Context) {
+	searches := config.SavedSearches()
+	id := auth.Identity(c)
+
+	resp := make([]SavedSearchResponse, 0, len(searches))
+	for _, s := range searches {
+		resp = append(resp, SavedSearchResponse{SavedSearch: s, State: visibleState(id, saved.GetState(s.Name))})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"saved_searches": resp})
+}
+
+func AddSavedSearch(c *gin.Context) {
+	var req config.SavedSearch
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || strings.TrimSpace(req.Query) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and query are required"})
+		return
+	}
+
+	if err := config.AddSavedSearch(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Record the baseline so the next build reports only genuinely new matches
+	if req.Watch {
+		if _, err := saved.Evaluate(c.Request.Context(), req); err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "saved search added, baseline not recorded: " + err.Error(), "saved_search": req})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "saved search added", "saved_search": req})
+}
+
+// RunSavedSearch runs a saved search by name for the user without recording
+// anything.
+func RunSavedSearch(c *gin.Context) {
+	search, ok := config.FindSavedSearch(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "saved search '" + c.Param("name") + "' not found"})
+		return
+	}
+
+	id := auth.Identity(c)
+	results, err := saved.Run(c.Request.Context(), search, id)
+	if !savedSearchError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"saved_search": search,
+		"results":      results,
+		"count":        len(results),
+		"state":        visibleState(id, saved.GetState(search.Name)),
+	})
+}
+
+// EvaluateSavedSearch runs a saved search and records the results as a new
+// evaluation, as after an index build, publishing any new matches.
+func EvaluateSavedSearch(c *gin.Context) {
+	search, ok := config.FindSavedSearch(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "saved search '" + c.Param("name") + "' not found"})
+		return
+	}
+
+	state, err := saved.Evaluate(c.Request.Context(), search)
+	if !savedSearchError(c, err) {
+		return
+	}
+
+	id := auth.Identity(c)
+	results := id.FilterResults(state.Results)
+	c.JSON(http.StatusOK, gin.H{
+		"saved_search": search,
+		"results":      results,
+		"count":        len(results),
+		"state":        visibleState(id, saved.GetState(search.Name)),
+	})
+}
+
+// savedSearchError writes the response for a failed saved search run and
+// reports whether err was nil.
+func savedSearchError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, config.ErrAccessDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, indexer.ErrSearchBusy):
+		throttle.Reject(c, throttle.ReasonBusy, time.Second)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+func RemoveSavedSearch(c *gin.Context) {
+	name := c.Param("name")
+
+	if err := config.RemoveSavedSearch(name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	saved.Forget(name)
+
+	c.JSON(http.StatusOK, gin.H{"message": "saved search removed"})
+}
+
+// visibleState strips a recorded state down to what id may see. Recorded
+// matches come from unrestricted runs, so they are filtered here.
+func visibleState(id *config.Identity, state *saved.State) *saved.State {
+	if state == nil {
+		return nil
+	}
+	state.Count = len(id.FilterResults(state.Results))
+	state.NewMatches = id.FilterResults(state.NewMatches)
+	state.Results = nil
+	return state
+}
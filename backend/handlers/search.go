@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"plocate-ui/auth"
+	"plocate-ui/config"
 	"plocate-ui/indexer"
+	"plocate-ui/throttle"
 
 	"github.com/gin-gonic/gin"
 )
@@ -52,9 +57,16 @@ func Search(c *gin.Context) {
 	if req.Limit > 1000 {
 		req.Limit = 1000
 	}
+	c.Set(searchIndexKey, searchIndexLabel(req.Indices))
 
-	results, err := indexer.Instance.Search(req.Query, req.Limit, req.Indices)
-	if err != nil {
+	results, err := indexer.Instance.Search(c.Request.Context(), req.Query, req.Limit, req.Indices, auth.Identity(c))
+	if errors.Is(err, config.ErrAccessDenied) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	} else if errors.Is(err, indexer.ErrSearchBusy) {
+		throttle.Reject(c, throttle.ReasonBusy, time.Second)
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -64,3 +76,18 @@ func Search(c *gin.Context) {
 		Count:   len(results),
 	})
 }
+
+// GetSearchLimits returns the configured search limits and how many searches
+// each has rejected since the server started.
+func GetSearchLimits(c *gin.Context) {
+	limits := config.AppConfig.Search
+	c.JSON(http.StatusOK, gin.H{
+		"rate_limit":        limits.RateLimit,
+		"rate_burst":        limits.RateBurst,
+		"global_rate_limit": limits.GlobalRateLimit,
+		"global_burst":      limits.GlobalBurst,
+		"max_concurrent":    limits.MaxConcurrent,
+		"queue_timeout":     limits.QueueTimeout,
+		"stats":             throttle.GetStats(),
+	})
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"plocate-ui/auth"
+	"plocate-ui/indexer"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetIndexStats(c *gin.Context) {
+	stats, err := indexer.Instance.Stats(c.Param("indexName"))
+	if errors.Is(err, indexer.ErrStatsDisabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if id := auth.Identity(c); id.HasPathRules() {
+		dirs := []indexer.DirCount{}
+		for _, d := range stats.TopDirectories {
+			if id.PathAllowed(d.Path) {
+				dirs = append(dirs, d)
+			}
+		}
+		stats.TopDirectories = dirs
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -3,12 +3,35 @@ package handlers
 import (
 	"net/http"
 
+	"plocate-ui/auth"
+	"plocate-ui/config"
 	"plocate-ui/indexer"
 
 	"github.com/gin-gonic/gin"
 )
 
 func GetStatus(c *gin.Context) {
-	status := indexer.Instance.GetStatus()
+	status := visibleStatus(auth.Identity(c), indexer.Instance.GetStatus())
 	c.JSON(http.StatusOK, status)
 }
+
+// visibleStatus drops the indices id may not search, and their queue
+// entries, from a status snapshot.
+func visibleStatus(id *config.Identity, status indexer.Status) indexer.Status {
+	indices := make([]indexer.IndexStatus, 0, len(status.Indices))
+	for _, s := range status.Indices {
+		if id.CanSearchIndex(s.Name) {
+			indices = append(indices, s)
+		}
+	}
+	status.Indices = indices
+
+	queue := make([]string, 0, len(status.Queue))
+	for _, name := range status.Queue {
+		if id.CanSearchIndex(name) {
+			queue = append(queue, name)
+		}
+	}
+	status.Queue = queue
+	return status
+}
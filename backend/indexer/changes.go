@@ -0,0 +1,255 @@
+package indexer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"plocate-ui/config"
+	"plocate-ui/metrics"
+)
+
+// ChangeSet lists the paths that appeared and disappeared between two
+// database generations of an index.
+type ChangeSet struct {
+	Index        string    `json:"index"`
+	Time         time.Time `json:"time"`
+	AddedCount   int       `json:"added_count"`
+	RemovedCount int       `json:"removed_count"`
+	Added        []string  `json:"added"`
+	Removed      []string  `json:"removed"`
+	Truncated    bool      `json:"truncated"` // Path lists capped at changes.max_paths
+
+	// Set when path rules hid paths from a truncated change set: the counts
+	// exclude the hidden paths in the lists but may include hidden paths
+	// beyond the cap, so they are upper bounds
+	CountsUpperBound bool `json:"counts_upper_bound,omitempty"`
+}
+
+// dumpSorted writes every path in a database to out in byte order. sort
+// spills to the directory of out rather than the OS temp dir.
+func dumpSorted(dbPath, out string) error {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dump := exec.Command(config.AppConfig.Plocate.PlocateBin, "--database", dbPath, "/")
+	sorter := exec.Command("sort", "-T", filepath.Dir(out))
+	sorter.Env = append(os.Environ(), "LC_ALL=C")
+	sorter.Stdout = f
+
+	pipe, err := dump.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	sorter.Stdin = pipe
+
+	if err := sorter.Start(); err != nil {
+		return fmt.Errorf("failed to start sort: %w", err)
+	}
+	metrics.PlocateProcesses.Inc("dump")
+	if err := dump.Run(); err != nil {
+		// Exit code 1 means the database is empty
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+			sorter.Wait()
+			return fmt.Errorf("failed to dump %s: %w", dbPath, err)
+		}
+	}
+	if err := sorter.Wait(); err != nil {
+		return fmt.Errorf("failed to sort dump of %s: %w", dbPath, err)
+	}
+	return nil
+}
+
+// diffGenerations compares two generations by merging their sorted dumps.
+func diffGenerations(indexName, oldDump, newDump string) (*ChangeSet, error) {
+	oldFile, err := os.Open(oldDump)
+	if err != nil {
+		return nil, err
+	}
+	defer oldFile.Close()
+	newFile, err := os.Open(newDump)
+	if err != nil {
+		return nil, err
+	}
+	defer newFile.Close()
+
+	cs := &ChangeSet{Index: indexName, Time: time.Now(), Added: []string{}, Removed: []string{}}
+	maxPaths := config.AppConfig.Changes.MaxPaths
+
+	added := func(path string) {
+		cs.AddedCount++
+		if len(cs.Added) < maxPaths {
+			cs.Added = append(cs.Added, path)
+		} else {
+			cs.Truncated = true
+		}
+	}
+	removed := func(path string) {
+		cs.RemovedCount++
+		if len(cs.Removed) < maxPaths {
+			cs.Removed = append(cs.Removed, path)
+		} else {
+			cs.Truncated = true
+		}
+	}
+
+	oldScan := bufio.NewScanner(oldFile)
+	newScan := bufio.NewScanner(newFile)
+	oldScan.Buffer(make([]byte, 64*1024), 1024*1024)
+	newScan.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	oldOK, newOK := oldScan.Scan(), newScan.Scan()
+	for oldOK || newOK {
+		switch {
+		case !newOK || (oldOK && oldScan.Text() < newScan.Text()):
+			removed(oldScan.Text())
+			oldOK = oldScan.Scan()
+		case !oldOK || newScan.Text() < oldScan.Text():
+			added(newScan.Text())
+			newOK = newScan.Scan()
+		default:
+			oldOK, newOK = oldScan.Scan(), newScan.Scan()
+		}
+	}
+	if err := oldScan.Err(); err != nil {
+		return nil, err
+	}
+	if err := newScan.Err(); err != nil {
+		return nil, err
+	}
+
+	return cs, nil
+}
+
+func changesDir(indexName string) string {
+	return filepath.Join(config.AppConfig.Changes.Dir, indexName)
+}
+
+// recordChanges stores a change set and prunes records beyond retention.
+// Records are named like build logs, so change sets of builds finishing in
+// the same second are kept apart.
+func recordChanges(cs *ChangeSet) error {
+	dir := changesDir(cs.Index)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create changes directory: %w", err)
+	}
+
+	data, err := json.Marshal(cs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal changes: %w", err)
+	}
+	f, _, err := createRunFile(dir, cs.Time.UTC().Format(runIDFormat), ".json")
+	if err != nil {
+		return fmt.Errorf("failed to write changes: %w", err)
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to write changes: %w", err)
+	}
+
+	files, err := changeFiles(cs.Index)
+	if err != nil {
+		return err
+	}
+	for _, f := range files[min(len(files), config.AppConfig.Changes.Retain):] {
+		os.Remove(filepath.Join(dir, f))
+	}
+	return nil
+}
+
+// changeFiles lists stored change records for an index, newest first.
+func changeFiles(indexName string) ([]string, error) {
+	entries, err := os.ReadDir(changesDir(indexName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes: %w", err)
+	}
+
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			files = append(files, e.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files, nil
+}
+
+// trackChanges diffs the previous generation against the freshly swapped-in
+// database, given as its sorted dump, if change tracking is enabled and a
+// previous generation exists.
+func trackChanges(indexName, dbPath, newDump, tmpDir string, buildLog *BuildLog) {
+	if !config.AppConfig.Changes.Enabled {
+		return
+	}
+	prev := previousPath(dbPath)
+	if _, err := os.Stat(prev); err != nil {
+		return
+	}
+
+	oldDump := filepath.Join(tmpDir, "prev")
+	err := dumpSorted(prev, oldDump)
+	var cs *ChangeSet
+	if err == nil {
+		cs, err = diffGenerations(indexName, oldDump, newDump)
+	}
+	if err == nil {
+		err = recordChanges(cs)
+	}
+	if err != nil {
+		slog.Warn("change tracking failed", "index", indexName, "error", err)
+		buildLog.Printf("Change tracking failed: %v", err)
+		return
+	}
+
+	buildLog.Printf("Changes since previous generation: %d added, %d removed", cs.AddedCount, cs.RemovedCount)
+}
+
+// Changes returns the recorded change sets of an index newer than since,
+// newest first.
+func (idx *Indexer) Changes(indexName string, since time.Time) ([]ChangeSet, error) {
+	idx.mu.RLock()
+	_, exists := idx.indexStatuses[indexName]
+	idx.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("index '%s' not found", indexName)
+	}
+
+	files, err := changeFiles(indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := []ChangeSet{}
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(changesDir(indexName), f))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read changes: %w", err)
+		}
+		var cs ChangeSet
+		if err := json.Unmarshal(data, &cs); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f, err)
+		}
+		if !cs.Time.After(since) {
+			break
+		}
+		sets = append(sets, cs)
+	}
+	return sets, nil
+}
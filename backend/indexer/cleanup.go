@@ -0,0 +1,241 @@
+package indexer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"plocate-ui/config"
+)
+
+// Orphan is a database file or history directory left behind by an index that
+// is no longer configured.
+type Orphan struct {
+	Path    string    `json:"path"`
+	Kind    string    `json:"kind"` // "database", "logs" or "changes"
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// generationSuffixes are the files that sit next to a database during and
+// after builds and rollbacks.
+var generationSuffixes = []string{".new", ".prev", ".rollback"}
+
+// databaseFiles returns a database and the generations next to it.
+func databaseFiles(dbPath string) []string {
+	paths := []string{dbPath}
+	for _, suffix := range generationSuffixes {
+		paths = append(paths, dbPath+suffix)
+	}
+	return paths
+}
+
+// resolveDir returns the absolute path of path with symlinks in its parent
+// directories resolved, so a path can be compared against the data dir.
+func resolveDir(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		return filepath.Join(dir, filepath.Base(abs))
+	}
+	return abs
+}
+
+// inDataDir reports whether path lies under the data directory.
+func inDataDir(path string) bool {
+	dataDir, err := filepath.Abs(config.AppConfig.DataDir)
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(dataDir); err == nil {
+		dataDir = resolved
+	}
+	rel, err := filepath.Rel(dataDir, resolveDir(path))
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// PurgeIndexFiles deletes the build logs and change records of a removed
+// index, and its database and previous generations if the database is
+// managed or lies in the data directory. Databases elsewhere, such as the
+// system plocate database, are left alone and returned as skipped. It
+// returns the paths that were removed.
+func PurgeIndexFiles(index config.IndexConfig) (removed, skipped []string, err error) {
+	removed = []string{}
+	paths := []string{
+		filepath.Join(config.AppConfig.BuildLogs.Dir, index.Name),
+		changesDir(index.Name),
+	}
+	if index.Managed || inDataDir(index.DatabasePath) {
+		paths = append(databaseFiles(index.DatabasePath), paths...)
+	} else {
+		for _, path := range databaseFiles(index.DatabasePath) {
+			if _, err := os.Lstat(path); err == nil {
+				skipped = append(skipped, path)
+			}
+		}
+	}
+
+	for _, path := range paths {
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return removed, skipped, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, skipped, nil
+}
+
+// Orphans lists database files in the data directory, and build log and
+// change record directories, that no configured index refers to. Directories
+// holding anything but build logs or change records are left out.
+func Orphans() ([]Orphan, error) {
+	cfg := config.AppConfig
+
+	databases := make(map[string]bool)
+	names := make(map[string]bool)
+	if cfg.Plocate.DatabasePath != "" {
+		databases[filepath.Clean(cfg.Plocate.DatabasePath)] = true
+	}
+	for _, index := range cfg.Plocate.Indices {
+		databases[filepath.Clean(index.DatabasePath)] = true
+		names[index.Name] = true
+	}
+
+	orphans := []Orphan{}
+
+	entries, err := os.ReadDir(cfg.DataDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read data directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		path := filepath.Join(cfg.DataDir, entry.Name())
+		base := path
+		for _, suffix := range generationSuffixes {
+			base = strings.TrimSuffix(base, suffix)
+		}
+		if !strings.HasSuffix(base, ".db") || databases[base] {
+			continue
+		}
+		if orphan, ok := statOrphan(path, "database"); ok {
+			orphans = append(orphans, orphan)
+		}
+	}
+
+	// History directories outside the data directory may be shared with
+	// other software, so only ones in it are scanned
+	for kind, dir := range map[string]string{"logs": cfg.BuildLogs.Dir, "changes": cfg.Changes.Dir} {
+		if !inDataDir(dir) {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || names[entry.Name()] {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if !holdsOnlyRecords(path, historyExt[kind]) {
+				continue
+			}
+			if orphan, ok := statOrphan(path, kind); ok {
+				orphans = append(orphans, orphan)
+			}
+		}
+	}
+
+	return orphans, nil
+}
+
+// historyExt is the extension of the per-run files in each kind of history
+// directory.
+var historyExt = map[string]string{"logs": ".log", "changes": ".json"}
+
+// recordName matches the per-run files written to history directories: a
+// run timestamp, optionally with milliseconds and a suffix for runs that
+// started in the same millisecond, and the extension.
+var recordName = regexp.MustCompile(`^\d{8}T\d{6}(\.\d{3})?Z(-\d+)?\.(log|json)$`)
+
+// holdsOnlyRecords reports whether dir contains nothing but per-run files
+// with extension ext, i.e. looks like a history directory this app wrote.
+func holdsOnlyRecords(dir, ext string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || filepath.Ext(entry.Name()) != ext || !recordName.MatchString(entry.Name()) {
+			return false
+		}
+	}
+	return true
+}
+
+// statOrphan describes path, summing file sizes for directories.
+func statOrphan(path, kind string) (Orphan, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Orphan{}, false
+	}
+	orphan := Orphan{Path: path, Kind: kind, Size: info.Size(), ModTime: info.ModTime()}
+	if info.IsDir() {
+		orphan.Size = 0
+		filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+			if err == nil && d.Type().IsRegular() {
+				if info, err := d.Info(); err == nil {
+					orphan.Size += info.Size()
+				}
+			}
+			return nil
+		})
+	}
+	return orphan, true
+}
+
+// ReclaimOrphans deletes orphaned files. If paths is empty every orphan is
+// removed; otherwise only the listed paths, each of which must still be an
+// orphan when the scan is repeated.
+func ReclaimOrphans(paths []string) ([]Orphan, error) {
+	orphans, err := Orphans()
+	if err != nil {
+		return nil, err
+	}
+
+	byPath := make(map[string]Orphan, len(orphans))
+	for _, orphan := range orphans {
+		byPath[orphan.Path] = orphan
+	}
+
+	selected := orphans
+	if len(paths) > 0 {
+		selected = make([]Orphan, 0, len(paths))
+		for _, path := range paths {
+			orphan, ok := byPath[filepath.Clean(path)]
+			if !ok {
+				return nil, fmt.Errorf("%s is not an orphaned file", path)
+			}
+			selected = append(selected, orphan)
+		}
+	}
+
+	removed := []Orphan{}
+	for _, orphan := range selected {
+		if err := os.RemoveAll(orphan.Path); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", orphan.Path, err)
+		}
+		removed = append(removed, orphan)
+	}
+	return removed, nil
+}
@@ -0,0 +1,127 @@
+package indexer
+
+import (
+	"sync"
+	"time"
+
+	"plocate-ui/config"
+)
+
+// Event types published on the event bus.
+const (
+	EventBuildQueued   = "build_queued"
+	EventBuildStarted  = "build_started"
+	EventBuildProgress = "build_progress"
+	EventBuildFinished = "build_finished"
+	EventBuildFailed   = "build_failed"
+	EventBuildStopped  = "build_stopped"
+	EventIndexAdded    = "index_added"
+	EventIndexRemoved  = "index_removed"
+	EventIndexUpdated  = "index_updated"
+	EventScheduler     = "scheduler_toggled"
+	EventSavedSearch   = "saved_search_matches"
+)
+
+// Event is a status change in the indexer.
+type Event struct {
+	Type     string        `json:"type"`
+	Index    string        `json:"index,omitempty"`
+	Time     time.Time     `json:"time"`
+	Message  string        `json:"message,omitempty"`
+	Duration time.Duration `json:"duration_ns,omitempty"` // Build duration for finished/failed/stopped builds
+	Enabled  *bool         `json:"enabled,omitempty"`     // Scheduler state for scheduler_toggled
+	Search   string        `json:"search,omitempty"`      // Saved search name for saved_search_matches
+	Paths    []string      `json:"paths,omitempty"`       // New matches for saved_search_matches
+
+	// Request ID of the call or scheduled run that queued a build, on build
+	// events and the saved search matches they trigger
+	RequestID string `json:"request_id,omitempty"`
+
+	// Access settings of Index when the event was published, so events about
+	// an index that has since been removed or renamed stay private
+	access *config.IndexConfig
+}
+
+// VisibleTo reports whether id may see the event: events about an index need
+// the right to search it. Index events whose index was unknown when they were
+// published are only shown to admins.
+func (e Event) VisibleTo(id *config.Identity) bool {
+	if e.Index == "" {
+		return true
+	}
+	if e.access == nil {
+		return id == nil || id.Role == config.RoleAdmin
+	}
+	return id.CanSearch(*e.access)
+}
+
+// progressInterval limits how often a build's output lines are published as
+// progress events.
+const progressInterval = time.Second
+
+// eventBus fans events out to subscribers. Publishing never blocks: a
+// subscriber that falls behind misses events rather than stalling builds.
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[chan Event]struct{})}
+}
+
+func (b *eventBus) publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving all subsequent events and a function
+// that ends the subscription and closes the channel.
+func (idx *Indexer) Subscribe() (<-chan Event, func()) {
+	b := idx.events
+	ch := make(chan Event, 64)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (idx *Indexer) publish(e Event) {
+	idx.events.publish(withAccess(e))
+}
+
+// Publish sends an event from another package to all subscribers.
+func (idx *Indexer) Publish(e Event) {
+	idx.events.publish(withAccess(e))
+}
+
+// withAccess records the access settings of the event's index unless the
+// publisher already has.
+func withAccess(e Event) Event {
+	if e.Index != "" && e.access == nil {
+		if index, ok := config.FindIndex(e.Index); ok {
+			e.access = &index
+		}
+	}
+	return e
+}
@@ -0,0 +1,170 @@
+package indexer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"plocate-ui/config"
+	"plocate-ui/metrics"
+)
+
+// plocateMagic is the header every plocate database starts with.
+var plocateMagic = []byte("\x00plocate")
+
+// buildPath is where updatedb writes a new database before it is verified
+// and swapped into place.
+func buildPath(dbPath string) string {
+	return dbPath + ".new"
+}
+
+// previousPath holds the generation replaced by the most recent swap, kept so
+// it can be restored.
+func previousPath(dbPath string) string {
+	return dbPath + ".prev"
+}
+
+// verifyDatabase checks the plocate header and runs a one-result search
+// against the database to make sure plocate can read it.
+func verifyDatabase(dbPath string) error {
+	f, err := os.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open new database: %w", err)
+	}
+	header := make([]byte, len(plocateMagic))
+	_, err = io.ReadFull(f, header)
+	f.Close()
+	if err != nil || !bytes.Equal(header, plocateMagic) {
+		return fmt.Errorf("new database %s has an invalid plocate header", dbPath)
+	}
+
+	cmd := exec.Command(config.AppConfig.Plocate.PlocateBin, "--database", dbPath, "--limit", "1", "/")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	metrics.PlocateProcesses.Inc("verify")
+	if err := cmd.Run(); err != nil {
+		// Exit code 1 without a message just means the index is empty
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 && stderr.Len() == 0 {
+			return nil
+		}
+		return fmt.Errorf("sanity search on new database failed: %w - %s", err, stderr.String())
+	}
+
+	return nil
+}
+
+// swapDatabase atomically replaces dbPath with the verified build at newPath.
+// The current database is preserved as the previous generation first, so a
+// reader always sees either the old or the new database, never neither.
+func swapDatabase(dbPath, newPath string) error {
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := preserveFile(dbPath, previousPath(dbPath)); err != nil {
+			return fmt.Errorf("failed to keep previous generation: %w", err)
+		}
+	}
+
+	if err := os.Rename(newPath, dbPath); err != nil {
+		return fmt.Errorf("failed to move new database into place: %w", err)
+	}
+
+	return nil
+}
+
+// restorePrevious swaps the previous generation back into place. The
+// database it replaces becomes the new previous generation, so a restore can
+// itself be undone.
+func restorePrevious(dbPath string) error {
+	prev := previousPath(dbPath)
+	if _, err := os.Stat(prev); err != nil {
+		return fmt.Errorf("no previous generation available")
+	}
+
+	hold := dbPath + ".rollback"
+	hasCurrent := false
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := preserveFile(dbPath, hold); err != nil {
+			return fmt.Errorf("failed to keep current database: %w", err)
+		}
+		hasCurrent = true
+	}
+
+	if err := os.Rename(prev, dbPath); err != nil {
+		os.Remove(hold)
+		return fmt.Errorf("failed to restore previous generation: %w", err)
+	}
+
+	if hasCurrent {
+		if err := os.Rename(hold, prev); err != nil {
+			return fmt.Errorf("restored, but failed to keep replaced database: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// preserveFile makes dst a copy of src, preferring a hard link so large
+// databases are not duplicated on disk.
+func preserveFile(src, dst string) error {
+	os.Remove(dst)
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+// RestorePrevious rolls an index back to its previous database generation.
+func (idx *Indexer) RestorePrevious(indexName string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	status, exists := idx.indexStatuses[indexName]
+	if !exists {
+		return fmt.Errorf("index '%s' not found", indexName)
+	}
+	if status.IsIndexing || status.IsQueued {
+		return fmt.Errorf("index '%s' is being indexed", indexName)
+	}
+
+	if err := restorePrevious(status.DatabasePath); err != nil {
+		return err
+	}
+
+	// Cached stats describe the generation that was just replaced
+	delete(idx.stats, indexName)
+	return nil
+}
+
+// previousGeneration returns the modification time of the previous
+// generation, or the zero time if there is none.
+func previousGeneration(dbPath string) time.Time {
+	info, err := os.Stat(previousPath(dbPath))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
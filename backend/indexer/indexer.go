@@ -3,13 +3,19 @@ package indexer
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
 	"time"
 
 	"plocate-ui/config"
+	"plocate-ui/logging"
+	"plocate-ui/metrics"
 
 	"github.com/robfig/cron/v3"
 )
@@ -17,28 +23,69 @@ import (
 type IndexStatus struct {
 	Name          string    `json:"name"`
 	IsIndexing    bool      `json:"is_indexing"`
+	IsQueued      bool      `json:"is_queued"`
+	IsPaused      bool      `json:"is_paused"`                // Suspended by a blackout window
+	QueuePosition int       `json:"queue_position,omitempty"` // 1-based position while queued
+	QueuedAt      time.Time `json:"queued_at,omitempty"`
 	LastIndexed   time.Time `json:"last_indexed"`
 	LastError     string    `json:"last_error,omitempty"`
-	IndexedPaths  []string  `json:"indexed_paths"`
-	Enabled       bool      `json:"enabled"`
-	DatabasePath  string    `json:"database_path"`
+	PreviousBuild time.Time `json:"previous_build,omitempty"` // Build time of the rollback generation
+
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	NextRetry           time.Time `json:"next_retry,omitempty"`
+	CircuitOpen         bool      `json:"circuit_open"` // Scheduled runs suspended until failures are acknowledged
+	IndexedPaths        []string  `json:"indexed_paths"`
+	Enabled             bool      `json:"enabled"`
+	DatabasePath        string    `json:"database_path"`
 }
 
 type Status struct {
 	Indices       []IndexStatus `json:"indices"`
 	NextScheduled time.Time     `json:"next_scheduled"`
+	Queue         []string      `json:"queue"`   // Names of queued indices in dispatch order
+	Running       int           `json:"running"` // Number of builds currently running
+
+	// Scheduled run waiting for a blackout window, precondition or load guard
+	Deferred       bool   `json:"scheduler_deferred"`
+	DeferredReason string `json:"deferred_reason,omitempty"`
+	BlockedReason  string `json:"blocked_reason,omitempty"` // Active blackout window or failed precondition
 }
 
 type Indexer struct {
-	mu             sync.RWMutex
-	indexStatuses  map[string]*IndexStatus
-	cron           *cron.Cron
-	cancelFuncs    map[string]context.CancelFunc
-	nextScheduled  time.Time
+	mu            sync.RWMutex
+	indexStatuses map[string]*IndexStatus
+	cron          *cron.Cron
+	builds        map[string]*runningBuild
+	nextScheduled time.Time
+
+	// Build queue state, guarded by mu
+	queue       []*job
+	running     int
+	diskRunning map[string]int
+
+	// Maintenance window state, guarded by mu
+	procs          map[string]*os.Process
+	blockedReason  string
+	deferred       bool
+	deferredReason string
+	interrupted    []string
+
+	retryTimers map[string]*time.Timer
+	buildLogs   map[string]*BuildLog
+	stats       map[string]*IndexStats
+
+	// Holds a token per running plocate search; nil = unlimited
+	searchSlots chan struct{}
+
+	events *eventBus
 }
 
 var Instance *Indexer
 
+// ErrSearchBusy is returned by Search when every search slot stayed taken for
+// search.queue_timeout.
+var ErrSearchBusy = errors.New("too many searches running, try again shortly")
+
 func Initialize() error {
 	indexStatuses := make(map[string]*IndexStatus)
 
@@ -56,14 +103,26 @@ func Initialize() error {
 	Instance = &Indexer{
 		indexStatuses: indexStatuses,
 		cron:          cron.New(),
-		cancelFuncs:   make(map[string]context.CancelFunc),
+		builds:        make(map[string]*runningBuild),
+		diskRunning:   make(map[string]int),
+		procs:         make(map[string]*os.Process),
+		retryTimers:   make(map[string]*time.Timer),
+		buildLogs:     make(map[string]*BuildLog),
+		stats:         make(map[string]*IndexStats),
+		events:        newEventBus(),
 	}
+	if n := config.AppConfig.Search.MaxConcurrent; n > 0 {
+		Instance.searchSlots = make(chan struct{}, n)
+	}
+	removeStaleAnalysisDirs()
+
+	recheck, _ := time.ParseDuration(config.AppConfig.Scheduler.RecheckInterval)
+	Instance.blockedReason = blackoutReason(time.Now())
+	go Instance.watchMaintenance(recheck)
 
 	// Setup scheduled indexing (indexes all enabled indices)
 	if config.AppConfig.Scheduler.Enabled {
-		_, err := Instance.cron.AddFunc(config.AppConfig.Scheduler.Interval, func() {
-			_ = Instance.StartIndexingAll()
-		})
+		_, err := Instance.cron.AddFunc(config.AppConfig.Scheduler.Interval, Instance.runScheduled)
 		if err != nil {
 			return fmt.Errorf("failed to setup cron: %w", err)
 		}
@@ -81,12 +140,25 @@ func (idx *Indexer) GetStatus() Status {
 
 	indices := make([]IndexStatus, 0, len(idx.indexStatuses))
 	for _, status := range idx.indexStatuses {
-		indices = append(indices, *status)
+		s := *status
+		s.PreviousBuild = previousGeneration(status.DatabasePath)
+		indices = append(indices, s)
+	}
+
+	queue := make([]string, 0, len(idx.queue))
+	for _, j := range idx.queue {
+		queue = append(queue, j.name)
 	}
 
 	return Status{
 		Indices:       indices,
 		NextScheduled: idx.nextScheduled,
+		Queue:         queue,
+		Running:       idx.running,
+
+		Deferred:       idx.deferred,
+		DeferredReason: idx.deferredReason,
+		BlockedReason:  idx.blockedReason,
 	}
 }
 
@@ -101,53 +173,117 @@ func (idx *Indexer) GetIndexNames() []string {
 	return names
 }
 
-func (idx *Indexer) StartIndexing(indexName string) error {
+// StartIndexing queues a build for the given index. The build starts as soon
+// as the global and per-disk concurrency limits allow. The request ID in ctx,
+// or a new one, is logged with the run and set on its events.
+func (idx *Indexer) StartIndexing(ctx context.Context, indexName string) error {
 	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
 	status, exists := idx.indexStatuses[indexName]
 	if !exists {
-		idx.mu.Unlock()
 		return fmt.Errorf("index '%s' not found", indexName)
 	}
 
 	if status.IsIndexing {
-		idx.mu.Unlock()
 		return fmt.Errorf("index '%s' is already being indexed", indexName)
 	}
+	if status.IsQueued {
+		return fmt.Errorf("index '%s' is already queued", indexName)
+	}
+
+	requestID := logging.RequestID(ctx)
+	if requestID == "" {
+		requestID = logging.NewRequestID()
+		ctx = logging.WithRequestID(ctx, requestID)
+	}
 
+	idx.cancelRetry(indexName)
+	idx.enqueue(indexName, requestID)
+	logging.FromContext(ctx).Info("build queued", "index", indexName)
+	idx.dispatch()
+
+	return nil
+}
+
+// runningBuild is a launched build. Its address identifies the run, so a build
+// outliving its index does not unregister a newer build of the same name.
+type runningBuild struct {
+	cancel context.CancelFunc
+}
+
+// launch starts the updatedb run for a dequeued job. Caller must hold idx.mu.
+func (idx *Indexer) launch(j *job) {
+	status := idx.indexStatuses[j.name]
 	status.IsIndexing = true
 	status.LastError = ""
-	idx.mu.Unlock()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	idx.mu.Lock()
-	idx.cancelFuncs[indexName] = cancel
-	idx.mu.Unlock()
+	ctx, cancel := context.WithCancel(logging.WithRequestID(context.Background(), j.requestID))
+	build := &runningBuild{cancel: cancel}
+	idx.builds[j.name] = build
+
+	idx.running++
+	for _, disk := range j.disks {
+		idx.diskRunning[disk]++
+	}
+
+	idx.publish(Event{Type: EventBuildStarted, Index: j.name, RequestID: j.requestID})
+	started := time.Now()
 
 	go func() {
-		err := idx.runUpdatedb(ctx, indexName)
+		err := idx.runUpdatedb(ctx, j.name)
 
 		idx.mu.Lock()
 		status.IsIndexing = false
+		status.IsPaused = false
+		event := Event{Index: j.name, Duration: time.Since(started), RequestID: j.requestID}
 		if err != nil {
 			status.LastError = err.Error()
+			event.Message = err.Error()
+			// Builds stopped on request are not failures
+			if ctx.Err() == nil {
+				event.Type = EventBuildFailed
+				idx.recordFailure(status, j.requestID)
+				metrics.Builds.Inc(j.name, "failure")
+			} else {
+				event.Type = EventBuildStopped
+				metrics.Builds.Inc(j.name, "stopped")
+			}
 		} else {
 			status.LastIndexed = time.Now()
+			event.Type = EventBuildFinished
+			idx.recordSuccess(status)
+			metrics.Builds.Inc(j.name, "success")
+			metrics.BuildDuration.Observe(event.Duration.Seconds(), j.name)
+			if stats, ok := idx.stats[j.name]; ok {
+				stats.BuildDuration = event.Duration
+			}
+		}
+		idx.publish(event)
+		if idx.builds[j.name] == build {
+			delete(idx.builds, j.name)
 		}
-		delete(idx.cancelFuncs, indexName)
+
+		idx.running--
+		for _, disk := range j.disks {
+			idx.diskRunning[disk]--
+			if idx.diskRunning[disk] <= 0 {
+				delete(idx.diskRunning, disk)
+			}
+		}
+		idx.dispatch()
 		idx.mu.Unlock()
 
 		idx.updateNextScheduled()
 	}()
-
-	return nil
 }
 
-func (idx *Indexer) StartIndexingAll() error {
+func (idx *Indexer) StartIndexingAll(ctx context.Context) error {
 	var errors []string
 
 	for _, indexCfg := range config.AppConfig.Plocate.Indices {
 		if indexCfg.Enabled {
-			if err := idx.StartIndexing(indexCfg.Name); err != nil {
+			if err := idx.StartIndexing(ctx, indexCfg.Name); err != nil {
 				errors = append(errors, fmt.Sprintf("%s: %v", indexCfg.Name, err))
 			}
 		}
@@ -160,6 +296,49 @@ func (idx *Indexer) StartIndexingAll() error {
 	return nil
 }
 
+// runScheduled is the cron entry point. Unlike a manual start it is deferred
+// while a blackout window, precondition or the indexing.max_load guard
+// blocks indexing; the maintenance watcher starts it once allowed.
+func (idx *Indexer) runScheduled() {
+	if reason := scheduleBlockedReason(time.Now()); reason != "" {
+		slog.Info("scheduled indexing deferred", "reason", reason)
+		idx.mu.Lock()
+		idx.deferred = true
+		idx.deferredReason = reason
+		idx.mu.Unlock()
+		return
+	}
+
+	idx.startScheduledAll()
+}
+
+// startScheduledAll starts all enabled indices except those whose circuit
+// breaker is open. The builds of one scheduled run share a request ID.
+func (idx *Indexer) startScheduledAll() {
+	ctx := logging.WithRequestID(context.Background(), logging.NewRequestID())
+	logger := logging.FromContext(ctx)
+
+	for _, indexCfg := range config.AppConfig.Plocate.Indices {
+		if !indexCfg.Enabled {
+			continue
+		}
+
+		idx.mu.RLock()
+		status, exists := idx.indexStatuses[indexCfg.Name]
+		circuitOpen := exists && status.CircuitOpen
+		idx.mu.RUnlock()
+
+		if circuitOpen {
+			logger.Warn("scheduled build skipped, circuit breaker open", "index", indexCfg.Name)
+			continue
+		}
+		if err := idx.StartIndexing(ctx, indexCfg.Name); err != nil {
+			logger.Warn("scheduled build not started", "index", indexCfg.Name, "error", err)
+		}
+	}
+}
+
+// StopIndexing cancels a running build or removes a queued one.
 func (idx *Indexer) StopIndexing(indexName string) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
@@ -169,12 +348,21 @@ func (idx *Indexer) StopIndexing(indexName string) error {
 		return fmt.Errorf("index '%s' not found", indexName)
 	}
 
+	if status.IsQueued {
+		idx.dequeue(indexName)
+		return nil
+	}
+
 	if !status.IsIndexing {
+		if _, pending := idx.retryTimers[indexName]; pending {
+			idx.cancelRetry(indexName)
+			return nil
+		}
 		return fmt.Errorf("index '%s' is not being indexed", indexName)
 	}
 
-	if cancel, exists := idx.cancelFuncs[indexName]; exists {
-		cancel()
+	if build, exists := idx.builds[indexName]; exists {
+		build.cancel()
 	}
 
 	return nil
@@ -184,22 +372,28 @@ func (idx *Indexer) StopIndexingAll() error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	for name, cancel := range idx.cancelFuncs {
-		if cancel != nil {
-			cancel()
-		}
-		if status, exists := idx.indexStatuses[name]; exists {
-			status.IsIndexing = false
-		}
+	// Drop queued jobs and pending retries first so nothing restarts
+	for len(idx.queue) > 0 {
+		idx.dequeue(idx.queue[0].name)
+	}
+	for name := range idx.retryTimers {
+		idx.cancelRetry(name)
 	}
 
-	idx.cancelFuncs = make(map[string]context.CancelFunc)
+	// Running builds reset their own state once updatedb has exited, as in
+	// StopIndexing, so a new build cannot start while one is still writing
+	for _, build := range idx.builds {
+		build.cancel()
+	}
 	return nil
 }
 
 func (idx *Indexer) EnableScheduler() {
 	idx.cron.Start()
 	idx.updateNextScheduled()
+
+	enabled := true
+	idx.publish(Event{Type: EventScheduler, Enabled: &enabled})
 }
 
 func (idx *Indexer) DisableScheduler() {
@@ -207,6 +401,9 @@ func (idx *Indexer) DisableScheduler() {
 	idx.mu.Lock()
 	idx.nextScheduled = time.Time{}
 	idx.mu.Unlock()
+
+	enabled := false
+	idx.publish(Event{Type: EventScheduler, Enabled: &enabled})
 }
 
 // AddIndex registers a new index at runtime.
@@ -221,6 +418,8 @@ func (idx *Indexer) AddIndex(cfg config.IndexConfig) {
 		Enabled:      cfg.Enabled,
 		DatabasePath: cfg.DatabasePath,
 	}
+
+	idx.publish(Event{Type: EventIndexAdded, Index: cfg.Name})
 }
 
 // RemoveIndex stops and deregisters an index at runtime.
@@ -233,15 +432,27 @@ func (idx *Indexer) RemoveIndex(name string) error {
 		return fmt.Errorf("index '%s' not found", name)
 	}
 
-	// Stop if currently indexing
+	// Drop from queue or stop if currently indexing
+	idx.cancelRetry(name)
+	if status.IsQueued {
+		idx.dequeue(name)
+	}
 	if status.IsIndexing {
-		if cancel, ok := idx.cancelFuncs[name]; ok {
-			cancel()
-			delete(idx.cancelFuncs, name)
+		if build, ok := idx.builds[name]; ok {
+			build.cancel()
+			delete(idx.builds, name)
 		}
 	}
 
 	delete(idx.indexStatuses, name)
+	delete(idx.buildLogs, name)
+	delete(idx.stats, name)
+
+	event := Event{Type: EventIndexRemoved, Index: name}
+	if index, ok := config.FindIndex(name); ok {
+		event.access = &index
+	}
+	idx.publish(event)
 	return nil
 }
 
@@ -253,7 +464,37 @@ func (idx *Indexer) updateNextScheduled() {
 	}
 }
 
+// runUpdatedb builds the database for an index, capturing the output in a new
+// build log.
 func (idx *Indexer) runUpdatedb(ctx context.Context, indexName string) error {
+	buildLog := idx.startBuildLog(indexName)
+	defer pruneBuildLogs(indexName)
+	defer buildLog.Close()
+
+	requestID := logging.RequestID(ctx)
+	logger := logging.FromContext(ctx).With("index", indexName, "run", buildLog.RunID)
+	logger.Info("build started")
+	buildLog.Printf("Build started for index %s (request %s)", indexName, requestID)
+	start := time.Now()
+
+	err := idx.buildDatabase(ctx, indexName, buildLog)
+	duration := time.Since(start)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		logger.Info("build stopped", "duration", duration)
+		buildLog.Printf("Build stopped after %s", duration.Round(time.Second))
+	case err != nil:
+		logger.Error("build failed", "duration", duration, "error", err)
+		buildLog.Printf("Build failed after %s: %v", duration.Round(time.Second), err)
+	default:
+		logger.Info("build finished", "duration", duration)
+		buildLog.Printf("Build finished in %s", duration.Round(time.Second))
+	}
+
+	return err
+}
+
+func (idx *Indexer) buildDatabase(ctx context.Context, indexName string, buildLog *BuildLog) error {
 	cfg := config.AppConfig.Plocate
 
 	// Find the index configuration
@@ -269,9 +510,14 @@ func (idx *Indexer) runUpdatedb(ctx context.Context, indexName string) error {
 		return fmt.Errorf("index configuration for '%s' not found", indexName)
 	}
 
+	// Build into a side file so searches keep using the current database
+	// until the new one has been verified and swapped in
+	newPath := buildPath(indexCfg.DatabasePath)
+	defer os.Remove(newPath)
+
 	// Build updatedb command
 	args := []string{
-		"--output", indexCfg.DatabasePath,
+		"--output", newPath,
 		"--prunepaths", "",
 	}
 
@@ -280,23 +526,79 @@ func (idx *Indexer) runUpdatedb(ctx context.Context, indexName string) error {
 		args = append(args, "--database-root", path)
 	}
 
-	cmd := exec.CommandContext(ctx, cfg.UpdatedbBin, args...)
+	limits := resourceLimitsFor(*indexCfg)
+	bin, args := wrapCommand(limits, cfg.UpdatedbBin, args)
+	cmd := exec.CommandContext(ctx, bin, args...)
+
+	cmd.Stdout = buildLog
+	cmd.Stderr = buildLog
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("updatedb failed to start: %w", err)
+	}
+
+	if err := applyCgroupLimits(indexName, limits, cmd.Process.Pid); err != nil {
+		logging.FromContext(ctx).Warn("resource limits not applied", "index", indexName, "error", err)
+		buildLog.Printf("Resource limits not applied: %v", err)
+	}
+
+	idx.mu.Lock()
+	idx.procs[indexName] = cmd.Process
+	idx.mu.Unlock()
+
+	err := cmd.Wait()
+
+	idx.mu.Lock()
+	if idx.procs[indexName] == cmd.Process {
+		delete(idx.procs, indexName)
+	}
+	idx.mu.Unlock()
 
-	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("updatedb failed: %w - %s", err, string(output))
+		return fmt.Errorf("updatedb failed: %w - %s", err, buildLog.Tail(3))
 	}
 
+	if err := verifyDatabase(newPath); err != nil {
+		return err
+	}
+
+	if err := swapDatabase(indexCfg.DatabasePath, newPath); err != nil {
+		return err
+	}
+
+	idx.analyzeGeneration(indexName, indexCfg.DatabasePath, buildLog)
 	return nil
 }
 
-func (idx *Indexer) Search(query string, limit int, indexNames []string) ([]string, error) {
+// acquireSearchSlot waits for a free search slot, giving up with
+// ErrSearchBusy after search.queue_timeout.
+func (idx *Indexer) acquireSearchSlot() (func(), error) {
+	if idx.searchSlots == nil {
+		return func() {}, nil
+	}
+
+	timeout, _ := time.ParseDuration(config.AppConfig.Search.QueueTimeout)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case idx.searchSlots <- struct{}{}:
+		return func() { <-idx.searchSlots }, nil
+	case <-timer.C:
+		return nil, ErrSearchBusy
+	}
+}
+
+// Search runs a query against the named indices, or every enabled index id
+// may search when none are named. Results under paths hidden from id by path
+// rules are dropped; a nil id is unrestricted.
+func (idx *Indexer) Search(ctx context.Context, query string, limit int, indexNames []string, id *config.Identity) ([]string, error) {
 	cfg := config.AppConfig.Plocate
 
-	// If no indices specified, search all enabled indices
+	// If no indices specified, search all enabled indices the user may see
 	if len(indexNames) == 0 {
 		for _, indexCfg := range cfg.Indices {
-			if indexCfg.Enabled {
+			if indexCfg.Enabled && id.CanSearch(indexCfg) {
 				indexNames = append(indexNames, indexCfg.Name)
 			}
 		}
@@ -312,6 +614,9 @@ func (idx *Indexer) Search(query string, limit int, indexNames []string) ([]stri
 		found := false
 		for _, indexCfg := range cfg.Indices {
 			if indexCfg.Name == indexName {
+				if !id.CanSearch(indexCfg) {
+					return nil, fmt.Errorf("index '%s': %w", indexName, config.ErrAccessDenied)
+				}
 				dbPaths = append(dbPaths, indexCfg.DatabasePath)
 				found = true
 				break
@@ -328,29 +633,61 @@ func (idx *Indexer) Search(query string, limit int, indexNames []string) ([]stri
 	// Add all database paths (colon-separated as plocate expects)
 	args = append(args, "--database", strings.Join(dbPaths, ":"))
 
-	args = append(args, "--limit", fmt.Sprintf("%d", limit))
+	// With path rules the limit is applied after filtering, below
+	filtered := id.HasPathRules()
+	if !filtered {
+		args = append(args, "--limit", fmt.Sprintf("%d", limit))
+	}
 	args = append(args, "--ignore-case", query)
 
-	cmd := exec.Command(cfg.PlocateBin, args...)
+	release, err := idx.acquireSearchSlot()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Abandoned requests stop their plocate process
+	cmd := exec.CommandContext(ctx, cfg.PlocateBin, args...)
+	start := time.Now()
 
-	output, err := cmd.Output()
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		// plocate returns exit code 1 when no results found
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			return []string{}, nil
-		}
 		return nil, fmt.Errorf("plocate search failed: %w", err)
 	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("plocate search failed: %w", err)
+	}
+	metrics.PlocateProcesses.Inc("search")
 
-	// Parse results
-	var results []string
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	// Parse results, stopping plocate once enough visible ones are found
+	results := []string{}
+	truncated := false
+	scanner := bufio.NewScanner(stdout)
 	for scanner.Scan() {
 		line := scanner.Text()
-		if line != "" {
-			results = append(results, line)
+		if line == "" || (filtered && !id.PathAllowed(line)) {
+			continue
+		}
+		results = append(results, line)
+		if len(results) >= limit {
+			truncated = true
+			cmd.Process.Kill()
+			break
+		}
+	}
+	io.Copy(io.Discard, stdout)
+
+	if err := cmd.Wait(); err != nil && !truncated {
+		// plocate returns exit code 1 when no results found
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			results = []string{}
+		} else {
+			logging.FromContext(ctx).Warn("search failed", "indices", indexNames, "error", err)
+			return nil, fmt.Errorf("plocate search failed: %w", err)
 		}
 	}
 
+	logging.FromContext(ctx).Debug("search", "indices", indexNames, "results", len(results),
+		"truncated", truncated, "duration", time.Since(start))
 	return results, nil
 }
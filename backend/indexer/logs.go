@@ -0,0 +1,291 @@
+package indexer
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"plocate-ui/config"
+)
+
+// BuildLog captures the output of one updatedb run. The most recent lines are
+// kept in a ring buffer for live viewing and every line is appended to a log
+// file on disk.
+type BuildLog struct {
+	mu      sync.Mutex
+	RunID   string
+	lines   []string
+	start   int
+	count   int
+	done    bool
+	partial string
+	file    *os.File
+	subs    map[chan string]struct{}
+
+	// onLine, if set, is called with each line at most once per progressInterval
+	onLine       func(line string)
+	lastProgress time.Time
+}
+
+// BuildLogSnapshot is the buffered output of a run at a point in time.
+type BuildLogSnapshot struct {
+	RunID   string   `json:"run"`
+	Running bool     `json:"running"`
+	Lines   []string `json:"lines"`
+}
+
+// runIDFormat names per-run files: a UTC timestamp with millisecond
+// precision.
+const runIDFormat = "20060102T150405.000Z"
+
+// createRunFile creates <dir>/<id><ext> for a new run without overwriting an
+// existing file; if one exists, e.g. after a stop and immediate restart, a
+// numeric suffix keeps the runs apart. It returns the file and the ID used.
+func createRunFile(dir, id, ext string) (*os.File, string, error) {
+	base := id
+	for n := 2; ; n++ {
+		f, err := os.OpenFile(filepath.Join(dir, id+ext), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if !os.IsExist(err) {
+			return f, id, err
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// newBuildLog creates the log of a new run of the index.
+func newBuildLog(indexName string) *BuildLog {
+	l := &BuildLog{
+		RunID: time.Now().UTC().Format(runIDFormat),
+		lines: make([]string, config.AppConfig.BuildLogs.BufferLines),
+		subs:  make(map[chan string]struct{}),
+	}
+
+	dir := filepath.Join(config.AppConfig.BuildLogs.Dir, indexName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		slog.Warn("build log not stored", "index", indexName, "error", err)
+		return l
+	}
+	f, runID, err := createRunFile(dir, l.RunID, ".log")
+	if err != nil {
+		slog.Warn("build log not stored", "index", indexName, "error", err)
+		return l
+	}
+	l.file, l.RunID = f, runID
+	return l
+}
+
+// Write implements io.Writer so the log can be used as the stdout and stderr
+// of the updatedb command. Output is split into lines; a trailing partial line
+// is held until it is completed or the log is closed.
+func (l *BuildLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	data := l.partial + string(p)
+	parts := strings.Split(data, "\n")
+	l.partial = parts[len(parts)-1]
+	for _, line := range parts[:len(parts)-1] {
+		l.appendLine(line)
+	}
+	return len(p), nil
+}
+
+// Printf adds a line of plocate-ui's own commentary to the log.
+func (l *BuildLog) Printf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.appendLine(fmt.Sprintf(format, args...))
+}
+
+// appendLine stores a line and fans it out to followers. Caller must hold l.mu.
+func (l *BuildLog) appendLine(line string) {
+	line = strings.TrimRight(line, "\r")
+
+	size := len(l.lines)
+	if l.count < size {
+		l.lines[(l.start+l.count)%size] = line
+		l.count++
+	} else {
+		l.lines[l.start] = line
+		l.start = (l.start + 1) % size
+	}
+
+	if l.file != nil {
+		fmt.Fprintln(l.file, line)
+	}
+
+	if l.onLine != nil && time.Since(l.lastProgress) >= progressInterval {
+		l.lastProgress = time.Now()
+		l.onLine(line)
+	}
+
+	for ch := range l.subs {
+		select {
+		case ch <- line:
+		default:
+			// Slow follower; drop the line rather than block the build
+		}
+	}
+}
+
+// Close flushes any partial line, closes the log file and ends all follow
+// streams.
+func (l *BuildLog) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.partial != "" {
+		l.appendLine(l.partial)
+		l.partial = ""
+	}
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	for ch := range l.subs {
+		close(ch)
+	}
+	l.subs = make(map[chan string]struct{})
+	l.done = true
+}
+
+// Snapshot returns the buffered lines in order.
+func (l *BuildLog) Snapshot() BuildLogSnapshot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.snapshot()
+}
+
+func (l *BuildLog) snapshot() BuildLogSnapshot {
+	lines := make([]string, 0, l.count)
+	for i := 0; i < l.count; i++ {
+		lines = append(lines, l.lines[(l.start+i)%len(l.lines)])
+	}
+	return BuildLogSnapshot{RunID: l.RunID, Running: !l.done, Lines: lines}
+}
+
+// Tail returns the last n buffered lines joined by newlines.
+func (l *BuildLog) Tail(n int) string {
+	lines := l.Snapshot().Lines
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Follow returns the lines buffered so far and a channel that receives each
+// new line until the run ends, when the channel is closed. The returned
+// function stops following early.
+func (l *BuildLog) Follow() (BuildLogSnapshot, <-chan string, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch := make(chan string, 256)
+	snap := l.snapshot()
+	if l.done {
+		close(ch)
+		return snap, ch, func() {}
+	}
+
+	l.subs[ch] = struct{}{}
+	return snap, ch, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.subs[ch]; ok {
+			delete(l.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// startBuildLog creates the log for a new run of the index and makes it the
+// index's latest log.
+func (idx *Indexer) startBuildLog(indexName string) *BuildLog {
+	l := newBuildLog(indexName)
+	l.onLine = func(line string) {
+		idx.publish(Event{Type: EventBuildProgress, Index: indexName, Message: line})
+	}
+
+	idx.mu.Lock()
+	idx.buildLogs[indexName] = l
+	idx.mu.Unlock()
+
+	return l
+}
+
+// LatestBuildLog returns the log of the current or most recent run of the
+// index since startup, or nil if it has not been built yet.
+func (idx *Indexer) LatestBuildLog(indexName string) (*BuildLog, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if _, exists := idx.indexStatuses[indexName]; !exists {
+		return nil, fmt.Errorf("index '%s' not found", indexName)
+	}
+	return idx.buildLogs[indexName], nil
+}
+
+// BuildLogRuns lists the run IDs with a stored log for the index, newest first.
+func BuildLogRuns(indexName string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(config.AppConfig.BuildLogs.Dir, indexName))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list build logs: %w", err)
+	}
+
+	runs := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".log") {
+			runs = append(runs, strings.TrimSuffix(e.Name(), ".log"))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(runs)))
+	return runs, nil
+}
+
+// ReadBuildLog returns the stored lines of a past run.
+func ReadBuildLog(indexName, runID string) ([]string, error) {
+	if runID != filepath.Base(runID) || strings.HasPrefix(runID, ".") {
+		return nil, fmt.Errorf("invalid run id '%s'", runID)
+	}
+
+	f, err := os.Open(filepath.Join(config.AppConfig.BuildLogs.Dir, indexName, runID+".log"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("run '%s' not found for index '%s'", runID, indexName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open build log: %w", err)
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// pruneBuildLogs deletes stored logs beyond the configured retention.
+func pruneBuildLogs(indexName string) {
+	runs, err := BuildLogRuns(indexName)
+	if err != nil {
+		slog.Warn("build logs not pruned", "index", indexName, "error", err)
+		return
+	}
+
+	for _, run := range runs[min(len(runs), config.AppConfig.BuildLogs.Retain):] {
+		path := filepath.Join(config.AppConfig.BuildLogs.Dir, indexName, run+".log")
+		if err := os.Remove(path); err != nil {
+			slog.Warn("build log not pruned", "index", indexName, "run", run, "error", err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"syscall"
+	"time"
+
+	"plocate-ui/config"
+)
+
+// blackoutReason reports why indexing is currently blocked by a blackout
+// window or a failed precondition, or "" if indexing is allowed.
+func blackoutReason(now time.Time) string {
+	sched := config.AppConfig.Scheduler
+
+	for _, w := range sched.BlackoutWindows {
+		if windowContains(w, now) {
+			return fmt.Sprintf("blackout window %s-%s", w.Start, w.End)
+		}
+	}
+
+	for _, p := range sched.Preconditions {
+		if p.SentinelAbsent != "" {
+			if _, err := os.Stat(p.SentinelAbsent); err == nil {
+				return fmt.Sprintf("sentinel %s exists", p.SentinelAbsent)
+			}
+		}
+		if p.SentinelPresent != "" {
+			if _, err := os.Stat(p.SentinelPresent); err != nil {
+				return fmt.Sprintf("sentinel %s missing", p.SentinelPresent)
+			}
+		}
+	}
+
+	return ""
+}
+
+// scheduleBlockedReason extends blackoutReason with the indexing.max_load
+// guard, which only applies to starting scheduled runs.
+func scheduleBlockedReason(now time.Time) string {
+	if reason := blackoutReason(now); reason != "" {
+		return reason
+	}
+
+	if maxLoad := config.AppConfig.Indexing.MaxLoad; maxLoad > 0 {
+		load, err := systemLoad()
+		if err != nil {
+			slog.Warn("load average not checked", "error", err)
+		} else if load > maxLoad {
+			return fmt.Sprintf("load average %.2f exceeds %.2f", load, maxLoad)
+		}
+	}
+
+	return ""
+}
+
+func windowContains(w config.BlackoutWindow, now time.Time) bool {
+	start, _ := config.ParseClock(w.Start)
+	end, _ := config.ParseClock(w.End)
+	minute := now.Hour()*60 + now.Minute()
+
+	if start <= end {
+		return minute >= start && minute < end && windowDay(w, now.Weekday())
+	}
+
+	// Spans midnight: the early-morning part belongs to the previous day's window
+	if minute >= start {
+		return windowDay(w, now.Weekday())
+	}
+	return minute < end && windowDay(w, (now.Weekday()+6)%7)
+}
+
+func windowDay(w config.BlackoutWindow, day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if wd, err := config.ParseWeekday(d); err == nil && wd == day {
+			return true
+		}
+	}
+	return false
+}
+
+// watchMaintenance periodically re-evaluates blackout windows and
+// preconditions, applying the blackout action to running builds and starting
+// deferred scheduled runs once indexing is allowed.
+func (idx *Indexer) watchMaintenance(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		idx.checkMaintenance()
+	}
+}
+
+func (idx *Indexer) checkMaintenance() {
+	now := time.Now()
+	reason := blackoutReason(now)
+	action := config.AppConfig.Scheduler.BlackoutAction
+
+	idx.mu.Lock()
+	wasBlocked := idx.blockedReason != ""
+	idx.blockedReason = reason
+
+	var resume []string
+	switch {
+	case reason != "" && !wasBlocked:
+		slog.Info("indexing blocked", "reason", reason)
+		switch action {
+		case "pause":
+			idx.pauseRunning()
+		case "stop":
+			idx.interrupted = append(idx.interrupted, idx.stopRunning()...)
+		}
+	case reason == "" && wasBlocked:
+		slog.Info("indexing allowed again")
+		if action == "pause" {
+			idx.resumePaused()
+		}
+		resume = idx.interrupted
+		idx.interrupted = nil
+		idx.dispatch()
+	}
+
+	runDeferred := idx.deferred && scheduleBlockedReason(now) == ""
+	if runDeferred {
+		idx.deferred = false
+		idx.deferredReason = ""
+	}
+	idx.mu.Unlock()
+
+	for _, name := range resume {
+		if err := idx.StartIndexing(context.Background(), name); err != nil {
+			slog.Warn("interrupted build not restarted", "index", name, "error", err)
+		}
+	}
+
+	if runDeferred {
+		slog.Info("starting deferred scheduled indexing")
+		idx.startScheduledAll()
+	}
+}
+
+// holdQueue reports whether queued builds must wait for a blackout to end.
+// Caller must hold idx.mu.
+func (idx *Indexer) holdQueue() bool {
+	return idx.blockedReason != "" && config.AppConfig.Scheduler.BlackoutAction != "defer"
+}
+
+// pauseRunning suspends all running updatedb processes. Caller must hold idx.mu.
+func (idx *Indexer) pauseRunning() {
+	for name, proc := range idx.procs {
+		if err := proc.Signal(syscall.SIGSTOP); err != nil {
+			slog.Warn("build not paused", "index", name, "error", err)
+			continue
+		}
+		if status, ok := idx.indexStatuses[name]; ok {
+			status.IsPaused = true
+		}
+	}
+}
+
+// resumePaused continues all paused updatedb processes. Caller must hold idx.mu.
+func (idx *Indexer) resumePaused() {
+	for name, proc := range idx.procs {
+		status, ok := idx.indexStatuses[name]
+		if !ok || !status.IsPaused {
+			continue
+		}
+		if err := proc.Signal(syscall.SIGCONT); err != nil {
+			slog.Warn("build not resumed", "index", name, "error", err)
+			continue
+		}
+		status.IsPaused = false
+	}
+}
+
+// stopRunning cancels all running builds and returns their index names so
+// they can be restarted after the blackout. Caller must hold idx.mu.
+func (idx *Indexer) stopRunning() []string {
+	var names []string
+	for name, build := range idx.builds {
+		build.cancel()
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,152 @@
+package indexer
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"plocate-ui/config"
+)
+
+// job is a pending or running updatedb build.
+type job struct {
+	name      string
+	priority  int
+	queuedAt  time.Time
+	disks     []string
+	requestID string // Correlates the build with the request that queued it
+}
+
+// enqueue adds a build job for the index to the queue. Caller must hold idx.mu.
+func (idx *Indexer) enqueue(indexName, requestID string) {
+	j := &job{
+		name:      indexName,
+		queuedAt:  time.Now(),
+		requestID: requestID,
+	}
+	if indexCfg := findIndexConfig(indexName); indexCfg != nil {
+		j.priority = indexCfg.Priority
+		j.disks = diskKeys(*indexCfg)
+	}
+
+	idx.queue = append(idx.queue, j)
+	if config.AppConfig.Indexing.QueueOrder == "priority" {
+		// Stable sort keeps FIFO order among equal priorities
+		sort.SliceStable(idx.queue, func(a, b int) bool {
+			return idx.queue[a].priority > idx.queue[b].priority
+		})
+	}
+
+	status := idx.indexStatuses[indexName]
+	status.IsQueued = true
+	status.QueuedAt = j.queuedAt
+	idx.updateQueuePositions()
+
+	idx.publish(Event{Type: EventBuildQueued, Index: indexName, RequestID: requestID})
+}
+
+// dequeue removes a queued job without running it. Caller must hold idx.mu.
+func (idx *Indexer) dequeue(indexName string) {
+	for i, j := range idx.queue {
+		if j.name == indexName {
+			idx.queue = append(idx.queue[:i], idx.queue[i+1:]...)
+			break
+		}
+	}
+
+	if status, ok := idx.indexStatuses[indexName]; ok {
+		status.IsQueued = false
+		status.QueuePosition = 0
+		status.QueuedAt = time.Time{}
+	}
+	idx.updateQueuePositions()
+}
+
+// dispatch launches queued jobs in order while the global and per-disk
+// limits allow and no blackout is holding the queue. A job blocked on a busy
+// disk does not hold up jobs behind it that target other disks. Caller must
+// hold idx.mu.
+func (idx *Indexer) dispatch() {
+	if idx.holdQueue() {
+		return
+	}
+
+	limits := config.AppConfig.Indexing
+
+	remaining := idx.queue[:0]
+	for _, j := range idx.queue {
+		if !idx.canRun(j, limits.MaxConcurrent, limits.MaxPerDisk) {
+			remaining = append(remaining, j)
+			continue
+		}
+
+		status := idx.indexStatuses[j.name]
+		status.IsQueued = false
+		status.QueuePosition = 0
+		status.QueuedAt = time.Time{}
+		idx.launch(j)
+	}
+	idx.queue = remaining
+	idx.updateQueuePositions()
+}
+
+func (idx *Indexer) canRun(j *job, maxConcurrent, maxPerDisk int) bool {
+	if maxConcurrent > 0 && idx.running >= maxConcurrent {
+		return false
+	}
+	if maxPerDisk > 0 {
+		for _, disk := range j.disks {
+			if idx.diskRunning[disk] >= maxPerDisk {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (idx *Indexer) updateQueuePositions() {
+	for i, j := range idx.queue {
+		if status, ok := idx.indexStatuses[j.name]; ok {
+			status.QueuePosition = i + 1
+		}
+	}
+}
+
+// diskKeys returns the physical disks an index touches. An explicit Disk
+// setting wins; otherwise each index path is reduced to its first two
+// components (e.g. /mnt/disk1/media -> /mnt/disk1).
+func diskKeys(indexCfg config.IndexConfig) []string {
+	if indexCfg.Disk != "" {
+		return []string{indexCfg.Disk}
+	}
+
+	seen := make(map[string]bool)
+	var keys []string
+	for _, path := range indexCfg.IndexPaths {
+		key := pathPrefix(path)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func pathPrefix(path string) string {
+	parts := strings.SplitN(strings.TrimPrefix(filepath.Clean(path), "/"), "/", 3)
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+func findIndexConfig(indexName string) *config.IndexConfig {
+	indices := config.AppConfig.Plocate.Indices
+	for i := range indices {
+		if indices[i].Name == indexName {
+			return &indices[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package indexer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"plocate-ui/config"
+)
+
+// resourceLimitsFor returns the effective limits for an index: the global
+// indexing.resources with any per-index overrides applied.
+func resourceLimitsFor(indexCfg config.IndexConfig) config.ResourceLimits {
+	return config.AppConfig.Indexing.Resources.Merge(indexCfg.Resources)
+}
+
+// wrapCommand prefixes the updatedb invocation with nice and ionice as
+// configured. Both tools exec the target, so the PID seen by the cgroup and
+// by context cancellation is updatedb itself.
+func wrapCommand(limits config.ResourceLimits, bin string, args []string) (string, []string) {
+	argv := append([]string{bin}, args...)
+
+	if limits.IONiceClass != "" {
+		prefix := []string{"ionice", "-c", ioniceClassNumber(limits.IONiceClass)}
+		if limits.IONiceClass != "idle" {
+			prefix = append(prefix, "-n", strconv.Itoa(limits.IONicePriority))
+		}
+		argv = append(prefix, argv...)
+	}
+
+	if limits.Nice > 0 {
+		argv = append([]string{"nice", "-n", strconv.Itoa(limits.Nice)}, argv...)
+	}
+
+	return argv[0], argv[1:]
+}
+
+func ioniceClassNumber(class string) string {
+	switch class {
+	case "realtime":
+		return "1"
+	case "best-effort":
+		return "2"
+	default:
+		return "3"
+	}
+}
+
+// applyCgroupLimits moves pid into a per-index cgroup v2 group with the
+// configured cpu.max and io.max. It is a no-op when neither limit is set.
+func applyCgroupLimits(indexName string, limits config.ResourceLimits, pid int) error {
+	if limits.CPUMax == "" && limits.IOMax == "" {
+		return nil
+	}
+
+	root := config.AppConfig.Indexing.CgroupRoot
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("failed to create cgroup %s: %w", root, err)
+	}
+
+	// Delegate the controllers to the per-index children. This fails if the
+	// parent does not have them enabled, in which case the limit writes
+	// below report the real problem.
+	var controllers []string
+	if limits.CPUMax != "" {
+		controllers = append(controllers, "+cpu")
+	}
+	if limits.IOMax != "" {
+		controllers = append(controllers, "+io")
+	}
+	_ = os.WriteFile(filepath.Join(root, "cgroup.subtree_control"), []byte(strings.Join(controllers, " ")), 0644)
+
+	group := filepath.Join(root, indexName)
+	if err := os.MkdirAll(group, 0755); err != nil {
+		return fmt.Errorf("failed to create cgroup %s: %w", group, err)
+	}
+
+	if limits.CPUMax != "" {
+		if err := os.WriteFile(filepath.Join(group, "cpu.max"), []byte(limits.CPUMax), 0644); err != nil {
+			return fmt.Errorf("failed to set cpu.max: %w", err)
+		}
+	}
+	if limits.IOMax != "" {
+		if err := os.WriteFile(filepath.Join(group, "io.max"), []byte(limits.IOMax), 0644); err != nil {
+			return fmt.Errorf("failed to set io.max: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(group, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return fmt.Errorf("failed to move updatedb into cgroup: %w", err)
+	}
+
+	return nil
+}
+
+// systemLoad returns the 1-minute load average from /proc/loadavg.
+func systemLoad() (float64, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read load average: %w", err)
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected /proc/loadavg format")
+	}
+
+	return strconv.ParseFloat(fields[0], 64)
+}
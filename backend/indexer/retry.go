@@ -0,0 +1,113 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"plocate-ui/config"
+	"plocate-ui/logging"
+)
+
+// retryPolicyFor returns the effective retry policy for an index: the global
+// indexing.retry with any per-index overrides applied.
+func retryPolicyFor(indexName string) config.RetryPolicy {
+	policy := config.AppConfig.Indexing.Retry
+	if indexCfg := findIndexConfig(indexName); indexCfg != nil {
+		policy = policy.Merge(indexCfg.Retry)
+	}
+	return policy
+}
+
+// backoff returns the delay before the given retry attempt (1-based),
+// doubling from the initial backoff up to the maximum.
+func backoff(policy config.RetryPolicy, attempt int) time.Duration {
+	delay, _ := time.ParseDuration(policy.InitialBackoff)
+	limit, _ := time.ParseDuration(policy.MaxBackoff)
+
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	if limit > 0 && delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// recordFailure updates the failure counters for a failed build and either
+// schedules a retry or opens the circuit breaker. A retry keeps the request
+// ID of the failed build. Caller must hold idx.mu.
+func (idx *Indexer) recordFailure(status *IndexStatus, requestID string) {
+	status.ConsecutiveFailures++
+	policy := retryPolicyFor(status.Name)
+
+	if policy.CircuitBreaker > 0 && status.ConsecutiveFailures >= policy.CircuitBreaker {
+		status.CircuitOpen = true
+		status.NextRetry = time.Time{}
+		slog.Warn("circuit breaker open, scheduled builds suspended until acknowledged",
+			"index", status.Name, "failures", status.ConsecutiveFailures, "request_id", requestID)
+		return
+	}
+
+	if status.ConsecutiveFailures > policy.MaxAttempts {
+		status.NextRetry = time.Time{}
+		return
+	}
+
+	delay := backoff(policy, status.ConsecutiveFailures)
+	status.NextRetry = time.Now().Add(delay)
+
+	name, attempt := status.Name, status.ConsecutiveFailures
+	idx.retryTimers[name] = time.AfterFunc(delay, func() {
+		idx.mu.Lock()
+		delete(idx.retryTimers, name)
+		if s, ok := idx.indexStatuses[name]; ok {
+			s.NextRetry = time.Time{}
+		}
+		idx.mu.Unlock()
+
+		ctx := logging.WithRequestID(context.Background(), requestID)
+		logger := logging.FromContext(ctx)
+		logger.Info("retrying build", "index", name, "attempt", attempt)
+		if err := idx.StartIndexing(ctx, name); err != nil {
+			logger.Warn("retry not started", "index", name, "error", err)
+		}
+	})
+}
+
+// recordSuccess resets the failure counters after a successful build.
+// Caller must hold idx.mu.
+func (idx *Indexer) recordSuccess(status *IndexStatus) {
+	status.ConsecutiveFailures = 0
+	status.CircuitOpen = false
+	status.NextRetry = time.Time{}
+}
+
+// cancelRetry drops a pending retry for the index. Caller must hold idx.mu.
+func (idx *Indexer) cancelRetry(indexName string) {
+	if timer, ok := idx.retryTimers[indexName]; ok {
+		timer.Stop()
+		delete(idx.retryTimers, indexName)
+	}
+	if status, ok := idx.indexStatuses[indexName]; ok {
+		status.NextRetry = time.Time{}
+	}
+}
+
+// AcknowledgeFailures closes the circuit breaker for an index and resets its
+// failure counter so scheduled runs resume.
+func (idx *Indexer) AcknowledgeFailures(indexName string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	status, exists := idx.indexStatuses[indexName]
+	if !exists {
+		return fmt.Errorf("index '%s' not found", indexName)
+	}
+
+	idx.cancelRetry(indexName)
+	status.ConsecutiveFailures = 0
+	status.CircuitOpen = false
+	return nil
+}
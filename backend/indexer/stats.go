@@ -0,0 +1,285 @@
+package indexer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"plocate-ui/config"
+)
+
+// statsTopN is the number of directories and extensions listed in stats.
+const statsTopN = 25
+
+// IndexStats describes the contents of an index database.
+type IndexStats struct {
+	Index          string        `json:"index"`
+	ComputedAt     time.Time     `json:"computed_at"`
+	Entries        int           `json:"entries"`
+	DatabaseSize   int64         `json:"database_size"`
+	BuildDuration  time.Duration `json:"build_duration_ns,omitempty"` // Unknown for databases built before startup
+	TopDirectories []DirCount    `json:"top_directories"`             // By entries in the whole subtree, below the index paths
+	Extensions     []ExtCount    `json:"extensions"`                  // Top extensions; the rest are summed under "(other)"
+	Depths         []DepthCount  `json:"depths"`
+}
+
+type DirCount struct {
+	Path    string `json:"path"`
+	Entries int    `json:"entries"`
+}
+
+type ExtCount struct {
+	Extension string `json:"extension"` // Lower-case without the dot; "" for none
+	Count     int    `json:"count"`
+}
+
+type DepthCount struct {
+	Depth int `json:"depth"` // Path components below /
+	Count int `json:"count"`
+}
+
+// ErrStatsDisabled is returned for stats requests while stats.enabled is off.
+var ErrStatsDisabled = errors.New("index stats are disabled")
+
+// analysisPrefix starts the names of the temporary directories database
+// dumps are written to.
+const analysisPrefix = ".analyze-"
+
+// analysisDir creates a temporary directory for dumps of a database: next to
+// it if it lives in the data directory, else in the data directory. Dumps of
+// large databases take gigabytes, which the OS temp dir may not have room for.
+func analysisDir(indexName, dbPath string) (string, error) {
+	dir := config.AppConfig.DataDir
+	if inDataDir(dbPath) {
+		dir = filepath.Dir(dbPath)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dir, analysisPrefix+indexName+"-")
+}
+
+// removeStaleAnalysisDirs deletes dump directories left behind by a restart
+// during an analysis.
+func removeStaleAnalysisDirs() {
+	dirs := map[string]bool{config.AppConfig.DataDir: true}
+	for _, index := range config.AppConfig.Plocate.Indices {
+		if inDataDir(index.DatabasePath) {
+			dirs[filepath.Dir(index.DatabasePath)] = true
+		}
+	}
+	for dir := range dirs {
+		stale, _ := filepath.Glob(filepath.Join(dir, analysisPrefix+"*"))
+		for _, path := range stale {
+			os.RemoveAll(path)
+		}
+	}
+}
+
+// analyzeGeneration dumps a freshly built database once and derives both the
+// change set against the previous generation and the cached stats from it.
+// Nothing is dumped if change tracking and stats are both off.
+func (idx *Indexer) analyzeGeneration(indexName, dbPath string, buildLog *BuildLog) {
+	statsEnabled := config.AppConfig.StatsEnabled()
+	if !statsEnabled && !config.AppConfig.Changes.Enabled {
+		return
+	}
+
+	tmpDir, err := analysisDir(indexName, dbPath)
+	if err != nil {
+		slog.Warn("analysis skipped", "index", indexName, "error", err)
+		buildLog.Printf("Analysis skipped: %v", err)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dump := filepath.Join(tmpDir, "new")
+	if err := dumpSorted(dbPath, dump); err != nil {
+		slog.Warn("analysis skipped", "index", indexName, "error", err)
+		buildLog.Printf("Analysis skipped: %v", err)
+		return
+	}
+
+	trackChanges(indexName, dbPath, dump, tmpDir, buildLog)
+	if !statsEnabled {
+		return
+	}
+
+	stats, err := computeStats(indexName, dbPath, dump)
+	if err != nil {
+		slog.Warn("stats failed", "index", indexName, "error", err)
+		buildLog.Printf("Stats failed: %v", err)
+		return
+	}
+
+	idx.mu.Lock()
+	idx.stats[indexName] = stats
+	idx.mu.Unlock()
+
+	buildLog.Printf("Index contains %d entries", stats.Entries)
+}
+
+// computeStats scans a path dump of the database.
+func computeStats(indexName, dbPath, dump string) (*IndexStats, error) {
+	f, err := os.Open(dump)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stats := &IndexStats{Index: indexName, ComputedAt: time.Now()}
+	if info, err := os.Stat(dbPath); err == nil {
+		stats.DatabaseSize = info.Size()
+	}
+
+	// Entries are counted towards every ancestor below the index paths; the
+	// index paths themselves would always top the list
+	roots := make(map[string]bool)
+	if index, ok := config.FindIndex(indexName); ok {
+		for _, p := range index.IndexPaths {
+			roots[path.Clean(p)] = true
+		}
+	}
+
+	dirs := make(map[string]int)
+	exts := make(map[string]int)
+	depths := make(map[int]int)
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		p := scanner.Text()
+		if p == "" {
+			continue
+		}
+		stats.Entries++
+		for dir := p; ; {
+			i := strings.LastIndexByte(dir, '/')
+			if i <= 0 {
+				break
+			}
+			dir = dir[:i]
+			if roots[dir] {
+				break
+			}
+			if _, ok := dirs[dir]; !ok {
+				dir = strings.Clone(dir) // Don't keep the whole line alive
+			}
+			dirs[dir]++
+		}
+		exts[strings.ToLower(strings.TrimPrefix(path.Ext(path.Base(p)), "."))]++
+		depths[strings.Count(strings.Trim(p, "/"), "/")+1]++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dump: %w", err)
+	}
+
+	for dir, n := range dirs {
+		stats.TopDirectories = append(stats.TopDirectories, DirCount{Path: dir, Entries: n})
+	}
+	sort.Slice(stats.TopDirectories, func(a, b int) bool {
+		x, y := stats.TopDirectories[a], stats.TopDirectories[b]
+		return x.Entries > y.Entries || (x.Entries == y.Entries && x.Path < y.Path)
+	})
+	if len(stats.TopDirectories) > statsTopN {
+		stats.TopDirectories = stats.TopDirectories[:statsTopN]
+	}
+
+	for ext, n := range exts {
+		stats.Extensions = append(stats.Extensions, ExtCount{Extension: ext, Count: n})
+	}
+	sort.Slice(stats.Extensions, func(a, b int) bool {
+		x, y := stats.Extensions[a], stats.Extensions[b]
+		return x.Count > y.Count || (x.Count == y.Count && x.Extension < y.Extension)
+	})
+	if len(stats.Extensions) > statsTopN {
+		other := 0
+		for _, e := range stats.Extensions[statsTopN:] {
+			other += e.Count
+		}
+		stats.Extensions = append(stats.Extensions[:statsTopN], ExtCount{Extension: "(other)", Count: other})
+	}
+
+	for depth, n := range depths {
+		stats.Depths = append(stats.Depths, DepthCount{Depth: depth, Count: n})
+	}
+	sort.Slice(stats.Depths, func(a, b int) bool { return stats.Depths[a].Depth < stats.Depths[b].Depth })
+
+	return stats, nil
+}
+
+// CachedEntries returns the entry counts of indices whose stats have been
+// computed, without computing any.
+func (idx *Indexer) CachedEntries() map[string]int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	entries := make(map[string]int, len(idx.stats))
+	for name, s := range idx.stats {
+		entries[name] = s.Entries
+	}
+	return entries
+}
+
+// Stats returns the cached stats for an index, computing them from the
+// current database if none have been cached since startup.
+func (idx *Indexer) Stats(indexName string) (*IndexStats, error) {
+	if !config.AppConfig.StatsEnabled() {
+		return nil, ErrStatsDisabled
+	}
+
+	idx.mu.RLock()
+	status, exists := idx.indexStatuses[indexName]
+	var dbPath string
+	if exists {
+		dbPath = status.DatabasePath
+	}
+	var cached *IndexStats
+	if s, ok := idx.stats[indexName]; ok {
+		copied := *s
+		cached = &copied
+	}
+	idx.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("index '%s' not found", indexName)
+	}
+	if cached != nil {
+		return cached, nil
+	}
+
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("index '%s' has not been built yet", indexName)
+	}
+
+	tmpDir, err := analysisDir(indexName, dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dump directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dump := filepath.Join(tmpDir, "dump")
+	if err := dumpSorted(dbPath, dump); err != nil {
+		return nil, err
+	}
+	stats, err := computeStats(indexName, dbPath, dump)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mu.Lock()
+	if _, ok := idx.stats[indexName]; !ok {
+		idx.stats[indexName] = stats
+	}
+	idx.mu.Unlock()
+
+	s := *stats
+	return &s, nil
+}
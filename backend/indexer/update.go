@@ -0,0 +1,120 @@
+package indexer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"plocate-ui/config"
+)
+
+// UpdateIndex changes an index's configuration and live status. Renames move
+// the database, its previous generation, build logs and change records to the
+// new name. Updates are rejected while the index is being built or queued.
+func (idx *Indexer) UpdateIndex(name string, update config.IndexUpdate) (*config.IndexConfig, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	status, exists := idx.indexStatuses[name]
+	if !exists {
+		return nil, fmt.Errorf("index '%s' not found", name)
+	}
+	if status.IsIndexing || status.IsQueued {
+		return nil, fmt.Errorf("index '%s' is being indexed; stop it before editing", name)
+	}
+
+	renaming := update.Name != nil && *update.Name != name
+	newDBPath := status.DatabasePath
+	var moved [][2]string
+
+	if renaming {
+		if err := config.ValidateIndexName(*update.Name); err != nil {
+			return nil, err
+		}
+		if _, taken := idx.indexStatuses[*update.Name]; taken {
+			return nil, fmt.Errorf("index '%s' already exists", *update.Name)
+		}
+		indexCfg, ok := config.FindIndex(name)
+		if !ok {
+			return nil, fmt.Errorf("index '%s' not found", name)
+		}
+
+		newDBPath = config.RenamedDatabasePath(indexCfg, *update.Name)
+		renames := [][2]string{
+			{status.DatabasePath, newDBPath},
+			{previousPath(status.DatabasePath), previousPath(newDBPath)},
+			{filepath.Join(config.AppConfig.BuildLogs.Dir, name), filepath.Join(config.AppConfig.BuildLogs.Dir, *update.Name)},
+			{changesDir(name), changesDir(*update.Name)},
+		}
+		for _, r := range renames {
+			if _, err := os.Stat(r[0]); err != nil {
+				continue
+			}
+			if _, err := os.Stat(r[1]); err == nil {
+				rollbackRenames(moved)
+				return nil, fmt.Errorf("cannot rename index: %s already exists", r[1])
+			}
+			if err := os.Rename(r[0], r[1]); err != nil {
+				rollbackRenames(moved)
+				return nil, fmt.Errorf("failed to move %s: %w", r[0], err)
+			}
+			moved = append(moved, r)
+		}
+	}
+
+	before, _ := config.FindIndex(name)
+	cfg, err := config.UpdateIndex(name, update, newDBPath)
+	if err != nil {
+		rollbackRenames(moved)
+		return nil, err
+	}
+
+	status.IndexedPaths = cfg.IndexPaths
+	status.Enabled = cfg.Enabled
+	status.DatabasePath = cfg.DatabasePath
+
+	if renaming {
+		idx.cancelRetry(name)
+		status.Name = cfg.Name
+		delete(idx.indexStatuses, name)
+		idx.indexStatuses[cfg.Name] = status
+
+		if l, ok := idx.buildLogs[name]; ok {
+			delete(idx.buildLogs, name)
+			idx.buildLogs[cfg.Name] = l
+		}
+		if s, ok := idx.stats[name]; ok {
+			delete(idx.stats, name)
+			s.Index = cfg.Name
+			idx.stats[cfg.Name] = s
+		}
+
+		idx.publish(Event{Type: EventIndexRemoved, Index: name, access: &before})
+		idx.publish(Event{Type: EventIndexAdded, Index: cfg.Name})
+	} else {
+		idx.publish(Event{Type: EventIndexUpdated, Index: name})
+	}
+
+	return cfg, nil
+}
+
+func rollbackRenames(moved [][2]string) {
+	for i := len(moved) - 1; i >= 0; i-- {
+		os.Rename(moved[i][1], moved[i][0])
+	}
+}
+
+// GetIndexStatus returns the live status of a single index.
+func (idx *Indexer) GetIndexStatus(name string) (IndexStatus, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	status, exists := idx.indexStatuses[name]
+	if !exists {
+		return IndexStatus{}, fmt.Errorf("index '%s' not found", name)
+	}
+
+	s := *status
+	s.PreviousBuild = previousGeneration(status.DatabasePath)
+	return s, nil
+}
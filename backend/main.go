@@ -2,14 +2,24 @@ package main
 
 import (
 	"embed"
+	"flag"
 	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"time"
 
+	"plocate-ui/audit"
+	"plocate-ui/auth"
 	"plocate-ui/config"
 	"plocate-ui/handlers"
 	"plocate-ui/indexer"
+	"plocate-ui/logging"
+	"plocate-ui/notify"
+	"plocate-ui/saved"
+	"plocate-ui/server"
+	"plocate-ui/throttle"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -19,64 +29,153 @@ import (
 var frontendFS embed.FS
 
 func main() {
+	configFile := flag.String("config", "", "path to config.yml (default $CONFIG_PATH or /app/config/config.yml)")
+	health := flag.Bool("health", false, "check that the running server is healthy, then exit 0 or 1")
+	flag.Parse()
+
+	// Health check mode, used by the Docker HEALTHCHECK
+	if *health {
+		serverCfg, err := config.ReadServerConfig(*configFile)
+		if err == nil {
+			err = server.Probe(serverCfg, 3*time.Second)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unhealthy: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("healthy")
+		return
+	}
+
 	// Load configuration
-	if err := config.Load(""); err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+	if err := config.Load(*configFile); err != nil {
+		fatal("failed to load config", err)
 	}
+	logging.Setup()
 
 	// Initialize indexer
 	if err := indexer.Initialize(); err != nil {
-		log.Fatalf("Failed to initialize indexer: %v", err)
+		fatal("failed to initialize indexer", err)
+	}
+
+	// Start webhook notifications
+	notify.Start()
+
+	// Start watching saved searches
+	if err := saved.Start(); err != nil {
+		fatal("failed to load saved searches", err)
 	}
 
 	// Setup Gin router
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
-
-	// CORS middleware
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-	}))
-
-	// API routes
-	api := r.Group("/api")
+	r := gin.New()
+	r.Use(logging.Middleware(), gin.Recovery())
+
+	// CORS middleware, only when other origins are configured
+	if origins := config.AppConfig.Server.CORSOrigins; len(origins) > 0 {
+		r.Use(cors.New(cors.Config{
+			AllowOrigins:     origins,
+			AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", logging.RequestIDHeader},
+			ExposeHeaders:    []string{"Content-Length", logging.RequestIDHeader},
+			AllowCredentials: true,
+		}))
+	}
+
+	// Liveness and readiness probes
+	r.GET("/healthz", handlers.Healthz)
+	r.GET("/readyz", handlers.Readyz)
+
+	// Prometheus metrics, for any signed-in user or API token
+	r.GET("/metrics", auth.Middleware(), handlers.Metrics)
+
+	// Sign-in and first-run setup are reachable without a session
+	public := r.Group("/api/auth", audit.Middleware())
+	{
+		public.GET("/status", handlers.AuthStatus)
+		public.POST("/setup", handlers.Setup)
+		public.POST("/login", handlers.Login)
+		public.POST("/logout", handlers.Logout)
+	}
+
+	// API routes. Every signed-in user is at least a viewer. Mutating calls,
+	// including ones refused below, are written to the audit log.
+	api := r.Group("/api", auth.Middleware(), audit.Middleware("/api/search"), auth.IndexAccess())
 	{
 		api.GET("/status", handlers.GetStatus)
+		api.GET("/events", handlers.StreamEvents)
 		api.GET("/indices", handlers.GetIndices)
-		api.GET("/search", handlers.Search)
-		api.POST("/search", handlers.Search)
-		api.POST("/control/start", handlers.StartIndexing)             // Start all enabled indices
-		api.POST("/control/start/:indexName", handlers.StartIndexing)  // Start specific index
-		api.POST("/control/stop", handlers.StopIndexing)               // Stop all indices
-		api.POST("/control/stop/:indexName", handlers.StopIndexing)    // Stop specific index
-		api.POST("/control/scheduler/enable", handlers.EnableScheduler)
-		api.POST("/control/scheduler/disable", handlers.DisableScheduler)
-		api.POST("/indices", handlers.AddIndex)
-		api.DELETE("/indices/:indexName", handlers.RemoveIndex)
+		api.GET("/search", handlers.ObserveSearch, throttle.Middleware(), handlers.Search)
+		api.POST("/search", handlers.ObserveSearch, throttle.Middleware(), handlers.Search)
+		api.GET("/search/limits", handlers.GetSearchLimits)
+		api.GET("/indices/:indexName", handlers.GetIndex)
+		api.GET("/indices/:indexName/logs", handlers.GetBuildLogs)
+		api.GET("/indices/:indexName/changes", handlers.GetChanges)
+		api.GET("/indices/:indexName/stats", handlers.GetIndexStats)
+		api.GET("/saved", handlers.ListSavedSearches)
+		api.GET("/saved/:name", throttle.Middleware(), handlers.RunSavedSearch)
+		api.PUT("/auth/password", handlers.ChangePassword)
+		api.GET("/auth/tokens", handlers.ListAPITokens)
+		api.POST("/auth/tokens", handlers.CreateAPIToken)
+		api.DELETE("/auth/tokens/:name", handlers.RevokeAPIToken)
+	}
+
+	// Operators run builds and the scheduler
+	operator := api.Group("", auth.RequireRole(config.RoleOperator))
+	{
+		operator.POST("/control/start", handlers.StartIndexing)            // Start all enabled indices
+		operator.POST("/control/start/:indexName", handlers.StartIndexing) // Start specific index
+		operator.POST("/control/stop", handlers.StopIndexing)              // Stop all indices
+		operator.POST("/control/stop/:indexName", handlers.StopIndexing)   // Stop specific index
+		operator.POST("/control/scheduler/enable", handlers.EnableScheduler)
+		operator.POST("/control/scheduler/disable", handlers.DisableScheduler)
+		operator.POST("/indices/:indexName/rollback", handlers.RollbackIndex)
+		operator.POST("/indices/:indexName/acknowledge", handlers.AcknowledgeFailures)
+		operator.POST("/saved", handlers.AddSavedSearch)
+		operator.POST("/saved/:name/evaluate", throttle.Middleware(), handlers.EvaluateSavedSearch)
+		operator.DELETE("/saved/:name", handlers.RemoveSavedSearch)
+	}
+
+	// Admins manage indices, files on disk, users and settings
+	admin := api.Group("", auth.RequireRole(config.RoleAdmin))
+	{
+		admin.POST("/indices", handlers.AddIndex)
+		admin.PATCH("/indices/:indexName", handlers.UpdateIndex)
+		admin.DELETE("/indices/:indexName", handlers.RemoveIndex)
+		admin.GET("/audit", handlers.GetAuditLog)
+		admin.GET("/orphans", handlers.ListOrphans)
+		admin.POST("/orphans/reclaim", handlers.ReclaimOrphans)
+		admin.POST("/notifications/test", handlers.TestNotification)
+		admin.GET("/fs/mounts", handlers.ListMounts)
+		admin.GET("/fs/browse", handlers.BrowseDirectory)
+		admin.GET("/auth/users", handlers.ListUsers)
+		admin.POST("/auth/users", handlers.AddUser)
+		admin.PATCH("/auth/users/:username", handlers.UpdateUser)
+		admin.DELETE("/auth/users/:username", handlers.RemoveUser)
 	}
 
 	// Serve frontend (embedded or from filesystem)
 	serveFrontend(r)
 
 	// Start server
-	port := config.AppConfig.Server.Port
-	log.Printf("Server starting on port %s", port)
-	log.Printf("Configured indices: %d", len(config.AppConfig.Plocate.Indices))
+	slog.Info("configured indices", "count", len(config.AppConfig.Plocate.Indices))
 
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	if err := server.Run(r); err != nil {
+		fatal("failed to start server", err)
 	}
 }
 
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
 func serveFrontend(r *gin.Engine) {
 	// Try to serve embedded frontend
 	distFS, err := fs.Sub(frontendFS, "frontend/dist")
 	if err != nil {
-		log.Println("Warning: embedded frontend not found, serving API only")
+		slog.Warn("embedded frontend not found, serving API only")
 		return
 	}
 
@@ -140,5 +239,5 @@ func serveFrontend(r *gin.Engine) {
 		c.Data(http.StatusOK, contentType, data)
 	})
 
-	fmt.Println("Serving embedded frontend")
+	slog.Info("serving embedded frontend")
 }
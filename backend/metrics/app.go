@@ -0,0 +1,25 @@
+package metrics
+
+// Metrics recorded by the indexer and handlers. Gauges such as database
+// sizes are read from the indexer when /metrics is scraped.
+var (
+	SearchRequests = NewCounterVec("plocate_ui_search_requests_total",
+		"Search requests by index and HTTP status code. index is \"all\" when none was named and \"multiple\" for several.",
+		IndexLabel, "code")
+	SearchDuration = NewHistogramVec("plocate_ui_search_duration_seconds",
+		"Search request latency by index.",
+		[]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		IndexLabel)
+
+	PlocateProcesses = NewCounterVec("plocate_ui_plocate_processes_total",
+		"plocate processes started, by purpose: search, verify (sanity check of a new build) or dump (change tracking and stats).",
+		"purpose")
+
+	Builds = NewCounterVec("plocate_ui_builds_total",
+		"Finished index builds by result: success, failure or stopped.",
+		IndexLabel, "result")
+	BuildDuration = NewHistogramVec("plocate_ui_build_duration_seconds",
+		"Duration of successful index builds.",
+		[]float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200, 14400},
+		IndexLabel)
+)
@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// IndexLabel is the label that names an index. Series carrying it can be
+// hidden from users who may not search that index.
+const IndexLabel = "index"
+
+// Sample is one value of a metric computed at scrape time.
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// metric is a registered counter or histogram family.
+type metric interface {
+	write(w io.Writer, visible func(index string) bool)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []metric
+)
+
+func register(m metric) {
+	registryMu.Lock()
+	registry = append(registry, m)
+	registryMu.Unlock()
+}
+
+// Write renders every registered metric in the Prometheus text format.
+// Series whose index label fails visible are left out.
+func Write(w io.Writer, visible func(index string) bool) {
+	registryMu.Lock()
+	metrics := append([]metric(nil), registry...)
+	registryMu.Unlock()
+
+	for _, m := range metrics {
+		m.write(w, visible)
+	}
+}
+
+// WriteSamples renders a metric whose values are computed at scrape time,
+// such as gauges read from the indexer. typ is "gauge" or "counter".
+func WriteSamples(w io.Writer, name, help, typ string, labels []string, samples []Sample) {
+	if len(samples) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	for _, s := range samples {
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(labels, s.LabelValues, "", ""), formatValue(s.Value))
+	}
+}
+
+// family holds the label names and per-series state shared by counters and
+// histograms. Series are keyed by their joined label values.
+type family[T any] struct {
+	name, help string
+	labels     []string
+	indexPos   int // Position of IndexLabel, or -1
+
+	mu     sync.Mutex
+	series map[string]*T
+	values map[string][]string
+}
+
+func newFamily[T any](name, help string, labels []string) family[T] {
+	pos := -1
+	for i, l := range labels {
+		if l == IndexLabel {
+			pos = i
+		}
+	}
+	return family[T]{
+		name: name, help: help, labels: labels, indexPos: pos,
+		series: make(map[string]*T),
+		values: make(map[string][]string),
+	}
+}
+
+// get returns the series for values, creating it with create. Caller must
+// hold f.mu.
+func (f *family[T]) get(values []string, create func() *T) *T {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s: got %d label values, want %d", f.name, len(values), len(f.labels)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = create()
+		f.series[key] = s
+		f.values[key] = append([]string(nil), values...)
+	}
+	return s
+}
+
+// sortedKeys returns the keys of visible series in a stable order. Caller
+// must hold f.mu.
+func (f *family[T]) sortedKeys(visible func(string) bool) []string {
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		if f.indexPos >= 0 && visible != nil && !visible(f.values[key][f.indexPos]) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	family[float64]
+}
+
+// NewCounterVec registers a counter.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newFamily[float64](name, help, labels)}
+	register(c)
+	return c
+}
+
+// Inc adds one to the series with the given label values.
+func (c *CounterVec) Inc(values ...string) {
+	c.mu.Lock()
+	*c.get(values, func() *float64 { return new(float64) })++
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) write(w io.Writer, visible func(string) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := c.sortedKeys(visible)
+	if len(keys) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, c.values[key], "", ""), formatValue(*c.series[key]))
+	}
+}
+
+type histogram struct {
+	counts []uint64 // Per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	family[histogram]
+	buckets []float64
+}
+
+// NewHistogramVec registers a histogram with the given upper bucket bounds.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{newFamily[histogram](name, help, labels), buckets}
+	register(h)
+	return h
+}
+
+// Observe records v in the series with the given label values.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.get(values, func() *histogram { return &histogram{counts: make([]uint64, len(h.buckets))} })
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *HistogramVec) write(w io.Writer, visible func(string) bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := h.sortedKeys(visible)
+	if len(keys) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range keys {
+		s, values := h.series[key], h.values[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, values, "le", formatValue(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, values, "", ""), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, values, "", ""), s.count)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels renders {name="value",...}, with an optional extra label
+// such as a histogram's le.
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, labelEscaper.Replace(values[i]))
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, extraName, extraValue)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
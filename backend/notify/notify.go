@@ -0,0 +1,319 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"plocate-ui/config"
+	"plocate-ui/indexer"
+)
+
+// Notification event types. Build results reuse the indexer event names.
+const (
+	EventBuildSucceeded = indexer.EventBuildFinished
+	EventBuildFailed    = indexer.EventBuildFailed
+	EventIndexStale     = "index_stale"
+	EventDiskSpaceLow   = "disk_space_low"
+	EventSavedSearch    = indexer.EventSavedSearch
+	EventTest           = "test"
+)
+
+// Payload is the JSON body posted to webhooks.
+type Payload struct {
+	Event           string    `json:"event"`
+	Index           string    `json:"index,omitempty"`
+	Time            time.Time `json:"time"`
+	Message         string    `json:"message,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds,omitempty"`
+	Search          string    `json:"search,omitempty"`
+	Paths           []string  `json:"paths,omitempty"`
+}
+
+// Result is the outcome of delivering a payload to one webhook.
+type Result struct {
+	Webhook  string `json:"webhook"`
+	Status   int    `json:"status,omitempty"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+var (
+	mu sync.Mutex
+	// Conditions already reported, so stale and disk-space warnings are sent
+	// once per occurrence rather than on every check
+	reported = make(map[string]bool)
+)
+
+// Start forwards indexer build results and saved search matches to the
+// configured webhooks and runs the periodic stale-index and disk-space checks.
+func Start() {
+	events, _ := indexer.Instance.Subscribe()
+	go func() {
+		for e := range events {
+			if e.Type != EventBuildSucceeded && e.Type != EventBuildFailed && e.Type != EventSavedSearch {
+				continue
+			}
+			if e.Type == EventBuildSucceeded {
+				resolve("stale:" + e.Index)
+			}
+			Send(Payload{
+				Event:           e.Type,
+				Index:           e.Index,
+				Time:            e.Time,
+				Message:         e.Message,
+				DurationSeconds: e.Duration.Seconds(),
+				Search:          e.Search,
+				Paths:           e.Paths,
+			})
+		}
+	}()
+
+	interval, _ := time.ParseDuration(config.AppConfig.Notifications.CheckInterval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			checkStale()
+			checkDiskSpace()
+		}
+	}()
+}
+
+// Send delivers the payload asynchronously to every webhook subscribed to its
+// event type.
+func Send(p Payload) {
+	for _, hook := range config.AppConfig.Notifications.Webhooks {
+		if !wants(hook, p.Event) {
+			continue
+		}
+		go func(hook config.WebhookConfig) {
+			if r := deliver(hook, p); r.Error != "" {
+				slog.Warn("webhook notification not delivered", "webhook", hook.Name,
+					"event", p.Event, "attempts", r.Attempts, "error", r.Error)
+			}
+		}(hook)
+	}
+}
+
+// SendTest synchronously delivers a test payload to the named webhook, or to
+// all webhooks if name is empty, ignoring event filters.
+func SendTest(name string) ([]Result, error) {
+	p := Payload{
+		Event:   EventTest,
+		Time:    time.Now(),
+		Message: "Test notification from plocate-ui",
+	}
+
+	results := []Result{}
+	for _, hook := range config.AppConfig.Notifications.Webhooks {
+		if name != "" && hook.Name != name {
+			continue
+		}
+		results = append(results, deliver(hook, p))
+	}
+
+	if name != "" && len(results) == 0 {
+		return nil, fmt.Errorf("webhook '%s' not found", name)
+	}
+	return results, nil
+}
+
+func wants(hook config.WebhookConfig, event string) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, e := range hook.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay is the wait before the first retry of a delivery, doubled for
+// each further one.
+var retryDelay = time.Second
+
+// retryable reports whether a failed delivery may succeed if repeated: the
+// request never got a response, or the endpoint is failing or rate limiting.
+// Other 4xx responses mean the webhook is misconfigured.
+func retryable(status int) bool {
+	return status == 0 || status >= 500 || status == http.StatusTooManyRequests
+}
+
+// deliver posts the payload, retrying with exponential backoff on network
+// errors, 5xx and 429 responses.
+func deliver(hook config.WebhookConfig, p Payload) Result {
+	result := Result{Webhook: hook.Name}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to encode payload: %v", err)
+		return result
+	}
+
+	timeout, _ := time.ParseDuration(hook.Timeout)
+	client := &http.Client{Timeout: timeout}
+
+	// An unusable URL fails the same way on every attempt
+	if _, err := http.NewRequest(http.MethodPost, hook.URL, nil); err != nil {
+		result.Error = fmt.Sprintf("invalid webhook request: %v", err)
+		return result
+	}
+
+	delay := retryDelay
+	for attempt := 0; attempt <= max(hook.MaxRetries, 0); attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		result.Attempts++
+
+		status, err := post(client, hook, p.Event, body)
+		result.Status = status
+		if err == nil {
+			result.Error = ""
+			return result
+		}
+		result.Error = err.Error()
+		if !retryable(status) {
+			break
+		}
+	}
+
+	return result
+}
+
+func post(client *http.Client, hook config.WebhookConfig, event string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("invalid webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "plocate-ui")
+	req.Header.Set("X-Plocate-Event", event)
+	if hook.Secret != "" {
+		req.Header.Set("X-Plocate-Signature", "sha256="+Sign(hook.Secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex HMAC-SHA256 of body keyed with secret, as sent in the
+// X-Plocate-Signature header.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkStale warns about enabled indices with no successful build within
+// notifications.stale_after.
+func checkStale() {
+	staleAfter, err := time.ParseDuration(config.AppConfig.Notifications.StaleAfter)
+	if err != nil || staleAfter <= 0 {
+		return
+	}
+
+	for _, status := range indexer.Instance.GetStatus().Indices {
+		if !status.Enabled {
+			continue
+		}
+
+		// Builds from before a restart are only known from the database file
+		built := status.LastIndexed
+		if built.IsZero() {
+			info, err := os.Stat(status.DatabasePath)
+			if err != nil {
+				continue
+			}
+			built = info.ModTime()
+		}
+
+		age := time.Since(built)
+		if age < staleAfter || !report("stale:"+status.Name) {
+			continue
+		}
+		Send(Payload{
+			Event:   EventIndexStale,
+			Index:   status.Name,
+			Time:    time.Now(),
+			Message: fmt.Sprintf("last successful build %s ago", age.Round(time.Minute)),
+		})
+	}
+}
+
+// checkDiskSpace warns when a filesystem holding index databases falls below
+// notifications.min_free_percent free space.
+func checkDiskSpace() {
+	minFree := config.AppConfig.Notifications.MinFreePercent
+	if minFree <= 0 {
+		return
+	}
+
+	dirs := make(map[string]bool)
+	for _, index := range config.AppConfig.Plocate.Indices {
+		dirs[filepath.Dir(index.DatabasePath)] = true
+	}
+
+	for dir := range dirs {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(dir, &st); err != nil || st.Blocks == 0 {
+			continue
+		}
+		free := float64(st.Bavail) / float64(st.Blocks) * 100
+
+		key := "disk:" + dir
+		if free >= minFree {
+			resolve(key)
+			continue
+		}
+		if !report(key) {
+			continue
+		}
+		Send(Payload{
+			Event:   EventDiskSpaceLow,
+			Time:    time.Now(),
+			Message: fmt.Sprintf("%s has %.1f%% free space (threshold %.1f%%)", dir, free, minFree),
+		})
+	}
+}
+
+// report marks a condition as reported and returns true if it was not
+// reported before.
+func report(key string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if reported[key] {
+		return false
+	}
+	reported[key] = true
+	return true
+}
+
+// resolve forgets a reported condition so it is reported again if it recurs.
+func resolve(key string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(reported, key)
+}
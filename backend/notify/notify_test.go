@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"plocate-ui/config"
+)
+
+func init() {
+	retryDelay = time.Millisecond
+}
+
+// received is a request captured by a stand-in webhook endpoint.
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// newEndpoint starts a stand-in webhook that answers each request with the
+// next status in statuses (the last one repeating) and passes it on.
+func newEndpoint(t *testing.T, statuses ...int) (*httptest.Server, <-chan received, *int32) {
+	t.Helper()
+	requests := make(chan received, 16)
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1))
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{header: r.Header.Clone(), body: body}
+		w.WriteHeader(statuses[min(n, len(statuses))-1])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests, &calls
+}
+
+func TestDeliverSignsBody(t *testing.T) {
+	srv, requests, _ := newEndpoint(t, http.StatusOK)
+	hook := config.WebhookConfig{Name: "signed", URL: srv.URL, Secret: "s3cret"}
+
+	r := deliver(hook, Payload{Event: EventBuildFailed, Index: "media", Message: "exit status 1"})
+	if r.Error != "" || r.Status != http.StatusOK || r.Attempts != 1 {
+		t.Fatalf("deliver = %+v, want one successful attempt", r)
+	}
+
+	req := <-requests
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(req.body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.header.Get("X-Plocate-Signature"); got != want {
+		t.Errorf("X-Plocate-Signature = %q, want %q", got, want)
+	}
+	if got := req.header.Get("X-Plocate-Event"); got != EventBuildFailed {
+		t.Errorf("X-Plocate-Event = %q, want %q", got, EventBuildFailed)
+	}
+
+	var p Payload
+	if err := json.Unmarshal(req.body, &p); err != nil || p.Index != "media" {
+		t.Errorf("body = %s (%v), want the payload", req.body, err)
+	}
+}
+
+func TestDeliverUnsignedWithoutSecret(t *testing.T) {
+	srv, requests, _ := newEndpoint(t, http.StatusOK)
+
+	deliver(config.WebhookConfig{Name: "plain", URL: srv.URL}, Payload{Event: EventTest})
+	if got := (<-requests).header.Get("X-Plocate-Signature"); got != "" {
+		t.Errorf("X-Plocate-Signature = %q without a secret", got)
+	}
+}
+
+func TestSendFiltersEvents(t *testing.T) {
+	srv, requests, calls := newEndpoint(t, http.StatusOK)
+	config.AppConfig = &config.Config{}
+	config.AppConfig.Notifications.Webhooks = []config.WebhookConfig{
+		{Name: "failures", URL: srv.URL, Events: []string{EventBuildFailed}},
+	}
+
+	Send(Payload{Event: EventBuildSucceeded, Index: "media"})
+	Send(Payload{Event: EventBuildFailed, Index: "media"})
+
+	select {
+	case req := <-requests:
+		if got := req.header.Get("X-Plocate-Event"); got != EventBuildFailed {
+			t.Errorf("delivered %q, want only %q", got, EventBuildFailed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscribed event not delivered")
+	}
+
+	// Give a wrongly sent event time to arrive
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("endpoint called %d times, want 1", n)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxRetries   int
+		wantAttempts int
+		wantError    bool
+	}{
+		{"success", []int{200}, 3, 1, false},
+		{"server error then success", []int{500, 503, 200}, 3, 3, false},
+		{"server error exhausts retries", []int{500}, 2, 3, true},
+		{"rate limited", []int{429, 200}, 3, 2, false},
+		{"bad request", []int{400}, 3, 1, true},
+		{"unauthorized", []int{401}, 3, 1, true},
+		{"not found", []int{404}, 3, 1, true},
+		{"gone", []int{410}, 3, 1, true},
+		{"retries disabled", []int{500}, -1, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _, calls := newEndpoint(t, tt.statuses...)
+			hook := config.WebhookConfig{Name: tt.name, URL: srv.URL, MaxRetries: tt.maxRetries}
+
+			r := deliver(hook, Payload{Event: EventTest})
+			if r.Attempts != tt.wantAttempts || int(atomic.LoadInt32(calls)) != tt.wantAttempts {
+				t.Errorf("attempts = %d, endpoint calls = %d, want %d", r.Attempts, atomic.LoadInt32(calls), tt.wantAttempts)
+			}
+			if (r.Error != "") != tt.wantError {
+				t.Errorf("error = %q, want error: %v", r.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestDeliverRetriesNetworkErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	r := deliver(config.WebhookConfig{Name: "down", URL: url, MaxRetries: 2}, Payload{Event: EventTest})
+	if r.Attempts != 3 || r.Error == "" || r.Status != 0 {
+		t.Errorf("deliver = %+v, want 3 failed attempts without a status", r)
+	}
+}
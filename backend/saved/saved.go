@@ -0,0 +1,253 @@
+package saved
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"plocate-ui/config"
+	"plocate-ui/indexer"
+	"plocate-ui/logging"
+)
+
+// maxEventPaths caps the number of new paths carried in a single event.
+const maxEventPaths = 100
+
+// State is what was recorded the last time a watched search was evaluated.
+type State struct {
+	LastRun    time.Time `json:"last_run"`
+	Count      int       `json:"count"`
+	NewMatches []string  `json:"new_matches"`
+	Results    []string  `json:"results,omitempty"` // Baseline for the next comparison
+
+	// The search hit its result limit. Which matches fall inside the limit
+	// can change between builds, so capped results are not compared.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+var (
+	mu     sync.Mutex
+	states map[string]*State
+)
+
+// retryBusy is how long a watched search that found every search slot busy
+// waits before it is evaluated again.
+const retryBusy = 5 * time.Second
+
+// Watched searches waiting to be evaluated, by name, with the request ID of
+// the build that triggered them. Repeated builds before an evaluation runs
+// collapse into one.
+var (
+	pendingMu sync.Mutex
+	pending   = make(map[string]string)
+	wake      = make(chan struct{}, 1)
+)
+
+func statePath() string {
+	return filepath.Join(config.AppConfig.DataDir, "saved_searches.json")
+}
+
+// Start loads the recorded state and re-evaluates watched saved searches
+// after each successful build of an index they cover. Evaluations run on a
+// worker so the event subscription keeps up while they wait for a search
+// slot; the bus drops events for subscribers that fall behind.
+func Start() error {
+	states = make(map[string]*State)
+
+	data, err := os.ReadFile(statePath())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read saved search state: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &states); err != nil {
+			return fmt.Errorf("failed to parse saved search state: %w", err)
+		}
+	}
+
+	events, _ := indexer.Instance.Subscribe()
+	go func() {
+		for e := range events {
+			if e.Type != indexer.EventBuildFinished {
+				continue
+			}
+			for _, search := range config.SavedSearches() {
+				if search.Watch && covers(search, e.Index) {
+					schedule(search.Name, e.RequestID)
+				}
+			}
+		}
+	}()
+	go evaluatePending()
+
+	return nil
+}
+
+// schedule queues a watched search for evaluation.
+func schedule(name, requestID string) {
+	pendingMu.Lock()
+	pending[name] = requestID
+	pendingMu.Unlock()
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// evaluatePending evaluates queued watched searches as they arrive. Searches
+// refused for lack of a search slot are queued again after retryBusy.
+func evaluatePending() {
+	for range wake {
+		pendingMu.Lock()
+		batch := pending
+		pending = make(map[string]string)
+		pendingMu.Unlock()
+
+		for name, requestID := range batch {
+			search, ok := config.FindSavedSearch(name)
+			if !ok || !search.Watch {
+				continue
+			}
+			ctx := logging.WithRequestID(context.Background(), requestID)
+			_, err := Evaluate(ctx, search)
+			if errors.Is(err, indexer.ErrSearchBusy) {
+				time.AfterFunc(retryBusy, func() { schedule(name, requestID) })
+				continue
+			}
+			if err != nil {
+				logging.FromContext(ctx).Warn("saved search not evaluated", "search", name, "error", err)
+			}
+		}
+	}
+}
+
+// covers reports whether a build of the index can change the search results.
+func covers(search config.SavedSearch, indexName string) bool {
+	if len(search.Indices) == 0 {
+		return true
+	}
+	for _, name := range search.Indices {
+		if name == indexName {
+			return true
+		}
+	}
+	return false
+}
+
+// Limit returns the result limit for a saved search.
+func Limit(search config.SavedSearch) int {
+	if search.Limit <= 0 || search.Limit > 1000 {
+		return 1000
+	}
+	return search.Limit
+}
+
+// Run executes a saved search for id without recording anything. A nil id
+// sees every result.
+func Run(ctx context.Context, search config.SavedSearch, id *config.Identity) ([]string, error) {
+	return indexer.Instance.Search(ctx, search.Query, Limit(search), search.Indices, id)
+}
+
+// Evaluate runs a saved search, records the matches that were not present on
+// the previous evaluation and publishes them as a saved_search_matches event.
+// The first evaluation only records a baseline, as does any evaluation where
+// this or the previous run hit the result limit. The event carries the
+// request ID in ctx.
+func Evaluate(ctx context.Context, search config.SavedSearch) (*State, error) {
+	results, err := Run(ctx, search, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	prev, seen := states[search.Name]
+	state := &State{
+		LastRun:    time.Now(),
+		Count:      len(results),
+		NewMatches: []string{},
+		Results:    results,
+		Truncated:  len(results) >= Limit(search),
+	}
+	if seen && !prev.Truncated && !state.Truncated {
+		known := make(map[string]bool, len(prev.Results))
+		for _, path := range prev.Results {
+			known[path] = true
+		}
+		for _, path := range results {
+			if !known[path] {
+				state.NewMatches = append(state.NewMatches, path)
+			}
+		}
+	}
+	states[search.Name] = state
+	err = saveLocked()
+	mu.Unlock()
+
+	if err != nil {
+		logging.FromContext(ctx).Warn("saved search state not stored", "search", search.Name, "error", err)
+	}
+
+	if n := len(state.NewMatches); n > 0 {
+		paths := state.NewMatches
+		if len(paths) > maxEventPaths {
+			paths = paths[:maxEventPaths]
+		}
+		indexer.Instance.Publish(indexer.Event{
+			Type:      indexer.EventSavedSearch,
+			Search:    search.Name,
+			Message:   fmt.Sprintf("%d new matches for %q", n, search.Query),
+			Paths:     paths,
+			RequestID: logging.RequestID(ctx),
+		})
+	}
+
+	return state, nil
+}
+
+// GetState returns the recorded state of a saved search, or nil if it has
+// not been evaluated yet.
+func GetState(name string) *State {
+	mu.Lock()
+	defer mu.Unlock()
+
+	state, ok := states[name]
+	if !ok {
+		return nil
+	}
+	s := *state
+	return &s
+}
+
+// Forget drops the recorded state of a removed saved search.
+func Forget(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	delete(states, name)
+	if err := saveLocked(); err != nil {
+		slog.Warn("saved search state not stored", "search", name, "error", err)
+	}
+}
+
+// saveLocked persists all states. Caller must hold mu.
+func saveLocked() error {
+	data, err := json.Marshal(states)
+	if err != nil {
+		return fmt.Errorf("failed to marshal saved search state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(statePath()), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tmp := statePath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write saved search state: %w", err)
+	}
+	return os.Rename(tmp, statePath())
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"plocate-ui/config"
+)
+
+// New returns an HTTP server for handler with the address, timeouts and
+// header limit from the server config.
+func New(handler http.Handler) *http.Server {
+	cfg := config.AppConfig.Server
+	duration := func(s string) time.Duration {
+		d, _ := time.ParseDuration(s)
+		return d
+	}
+
+	return &http.Server{
+		Addr:              net.JoinHostPort(cfg.Bind, cfg.Port),
+		Handler:           handler,
+		ReadTimeout:       duration(cfg.ReadTimeout),
+		ReadHeaderTimeout: duration(cfg.ReadHeaderTimeout),
+		WriteTimeout:      duration(cfg.WriteTimeout),
+		IdleTimeout:       duration(cfg.IdleTimeout),
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// Run serves handler over HTTP, or HTTPS when server.tls is configured,
+// until the listener fails.
+func Run(handler http.Handler) error {
+	srv := New(handler)
+	tlsCfg := config.AppConfig.Server.TLS
+
+	if !tlsCfg.Enabled() {
+		slog.Info("server listening", "url", "http://"+srv.Addr)
+		return srv.ListenAndServe()
+	}
+
+	if tlsCfg.SelfSigned {
+		created, err := ensureSelfSigned(tlsCfg.CertFile, tlsCfg.KeyFile)
+		if err != nil {
+			return err
+		}
+		if created {
+			slog.Info("generated self-signed certificate", "file", tlsCfg.CertFile)
+		}
+	}
+
+	certs, err := newCertReloader(tlsCfg.CertFile, tlsCfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	go certs.watch(certCheckInterval)
+
+	srv.TLSConfig = certs.tlsConfig()
+	slog.Info("server listening", "url", "https://"+srv.Addr)
+	return srv.ListenAndServeTLS("", "")
+}
+
+// Probe asks the server described by cfg for /healthz and returns an error
+// unless it answers 200. The certificate is not verified: the probe runs on
+// the same host and a self-signed certificate is common.
+func Probe(cfg config.ServerConfig, timeout time.Duration) error {
+	host := cfg.Bind
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	scheme := "http"
+	if cfg.TLS.Enabled() {
+		scheme = "https"
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	resp, err := client.Get(fmt.Sprintf("%s://%s/healthz", scheme, net.JoinHostPort(host, cfg.Port)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package throttle
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"plocate-ui/auth"
+	"plocate-ui/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reasons a search is rejected.
+const (
+	ReasonClient = "client_rate"
+	ReasonGlobal = "global_rate"
+	ReasonBusy   = "concurrency"
+)
+
+// bucket is a token bucket refilled at rate tokens per second up to burst.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) refill(now time.Time, rate, burst float64) {
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+}
+
+// wait is how long until the bucket holds a whole token.
+func (b *bucket) wait(rate float64) time.Duration {
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// Stats counts rejected searches since the server started.
+type Stats struct {
+	Rejected       uint64            `json:"rejected"`
+	RejectedBy     map[string]uint64 `json:"rejected_by"`
+	TrackedClients int               `json:"tracked_clients"`
+}
+
+var (
+	mu      sync.Mutex
+	clients = make(map[string]*bucket)
+	global  bucket
+	pruned  time.Time
+
+	rejectedClient atomic.Uint64
+	rejectedGlobal atomic.Uint64
+	rejectedBusy   atomic.Uint64
+)
+
+// allow takes a token for client from its own and the global bucket. When
+// either is empty nothing is taken and the reason and wait are returned.
+func allow(client string) (string, time.Duration) {
+	limits := config.AppConfig.Search
+	now := time.Now()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Drop clients whose buckets have refilled, so the map does not grow
+	// with every address seen
+	if now.Sub(pruned) > time.Minute {
+		for key, b := range clients {
+			b.refill(now, limits.RateLimit, float64(limits.RateBurst))
+			if b.tokens >= float64(limits.RateBurst) {
+				delete(clients, key)
+			}
+		}
+		pruned = now
+	}
+
+	var b *bucket
+	if limits.RateLimit > 0 {
+		b = clients[client]
+		if b == nil {
+			b = &bucket{}
+			clients[client] = b
+		}
+		b.refill(now, limits.RateLimit, float64(limits.RateBurst))
+		if b.tokens < 1 {
+			return ReasonClient, b.wait(limits.RateLimit)
+		}
+	}
+
+	if limits.GlobalRateLimit > 0 {
+		global.refill(now, limits.GlobalRateLimit, float64(limits.GlobalBurst))
+		if global.tokens < 1 {
+			return ReasonGlobal, global.wait(limits.GlobalRateLimit)
+		}
+		global.tokens--
+	}
+	if b != nil {
+		b.tokens--
+	}
+	return "", 0
+}
+
+// Reject answers a search with 429 Too Many Requests and a Retry-After of
+// at least a second, and counts it under reason.
+func Reject(c *gin.Context, reason string, retryAfter time.Duration) {
+	switch reason {
+	case ReasonClient:
+		rejectedClient.Add(1)
+	case ReasonGlobal:
+		rejectedGlobal.Add(1)
+	case ReasonBusy:
+		rejectedBusy.Add(1)
+	}
+
+	seconds := max(1, int(math.Ceil(retryAfter.Seconds())))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":       "too many searches, try again shortly",
+		"reason":      reason,
+		"retry_after": seconds,
+	})
+}
+
+// Middleware applies the per-client and global search rate limits. Clients
+// are told apart by IP address, as reported by auth.ClientIP.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if reason, wait := allow(auth.ClientIP(c)); reason != "" {
+			Reject(c, reason, wait)
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetStats returns the rejection counters.
+func GetStats() Stats {
+	mu.Lock()
+	tracked := len(clients)
+	mu.Unlock()
+
+	client, global, busy := rejectedClient.Load(), rejectedGlobal.Load(), rejectedBusy.Load()
+	return Stats{
+		Rejected: client + global + busy,
+		RejectedBy: map[string]uint64{
+			ReasonClient: client,
+			ReasonGlobal: global,
+			ReasonBusy:   busy,
+		},
+		TrackedClients: tracked,
+	}
+}